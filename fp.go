@@ -4,215 +4,3153 @@ package gofp
 
 import (
 	"bufio"
+	"bytes"
+	"container/heap"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
+	"math/rand"
+	"net/http"
+	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
+// ErrTimeout is returned when a Pipeline doesn't deliver in time.
+var ErrTimeout = errors.New("gofp: timeout")
+
 // Pipeline is a single-direction channel.
 type Pipeline <-chan interface{}
 
-// New creates a new Pipeline instances.
-func New(f func(ch chan<- interface{})) Pipeline {
-	out := make(chan interface{}, 1)
-	go func() {
-		defer close(out)
-		f(out)
-	}()
-	return out
+// New creates a new Pipeline instances.
+func New(f func(ch chan<- interface{})) Pipeline {
+	out := make(chan interface{}, 1)
+	go func() {
+		defer close(out)
+		f(out)
+	}()
+	return out
+}
+
+// ForEach creates a new Pipeline instances.
+func ForEach(vs ...interface{}) Pipeline {
+	return New(func(ch chan<- interface{}) {
+		for _, v := range vs {
+			ch <- v
+		}
+	})
+}
+
+// FromArray new Pipeline from any array or slice.
+func FromArray(array interface{}) Pipeline {
+	at := reflect.TypeOf(array)
+	av := reflect.ValueOf(array)
+	if at.Kind() != reflect.Array && at.Kind() != reflect.Slice {
+		panic("need slice or array")
+	}
+	return New(func(ch chan<- interface{}) {
+		for i := 0; i < av.Len(); i++ {
+			ch <- av.Index(i).Interface()
+		}
+	})
+}
+
+// MapOption configures FromMap.
+type MapOption func(*mapSource)
+
+type mapSource struct {
+	keys, values bool
+}
+
+// MapKeys makes FromMap emit only the keys.
+func MapKeys() MapOption {
+	return func(s *mapSource) {
+		s.keys, s.values = true, false
+	}
+}
+
+// MapValues makes FromMap emit only the values.
+func MapValues() MapOption {
+	return func(s *mapSource) {
+		s.keys, s.values = false, true
+	}
+}
+
+// FromMap new Pipeline from any map, emitting an Entry with `Key` and
+// `Value` for each of its entries, or only the keys or the values given
+// MapKeys or MapValues. Entries come in map iteration order, which is
+// unspecified.
+func FromMap(m interface{}, opts ...MapOption) Pipeline {
+	mv := reflect.ValueOf(m)
+	if mv.Kind() != reflect.Map {
+		panic("need map")
+	}
+	s := mapSource{keys: true, values: true}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return New(func(out chan<- interface{}) {
+		iter := mv.MapRange()
+		for iter.Next() {
+			switch {
+			case s.keys && s.values:
+				out <- Entry{Key: iter.Key().Interface(), Value: iter.Value().Interface()}
+			case s.keys:
+				out <- iter.Key().Interface()
+			default:
+				out <- iter.Value().Interface()
+			}
+		}
+	})
+}
+
+// FromChannel new Pipeline from any receivable channel, like
+// `chan string`, forwarding its values until it's closed.
+func FromChannel(ch interface{}) Pipeline {
+	switch ct := ch.(type) {
+	case chan interface{}:
+		return ct
+	case <-chan interface{}:
+		return ct
+	}
+	cv := reflect.ValueOf(ch)
+	if cv.Kind() != reflect.Chan || cv.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic(fmt.Sprintf("need receivable channel, got %T", ch))
+	}
+	return New(func(out chan<- interface{}) {
+		for {
+			v, ok := cv.Recv()
+			if !ok {
+				return
+			}
+			out <- v.Interface()
+		}
+	})
+}
+
+// Iterate returns an infinite Pipeline emitting `seed`, `f(seed)`,
+// `f(f(seed))` and so on. Use it with Take, TakeWhile or the like; the
+// generating goroutine stays blocked once the consumer stops reading.
+func Iterate(seed interface{}, f interface{}) Pipeline {
+	mf := toMapFunc(f)
+	return Unfold(seed, func(v interface{}) (interface{}, interface{}, bool) {
+		return v, mf.Map(v), true
+	})
+}
+
+// Unfold generates a Pipeline from `seed` with `f`, which returns the
+// next value to emit, the next seed, and whether to go on, e.g.
+// `func(seed S) (T, S, bool)`. The Pipeline closes when `f` returns
+// false, and the value returned along with it is not emitted.
+func Unfold(seed interface{}, f interface{}) Pipeline {
+	uf, ok := f.(func(interface{}) (interface{}, interface{}, bool))
+	if !ok {
+		uf = func(seed interface{}) (interface{}, interface{}, bool) {
+			results := callAll(f, seed)
+			return results[0].Interface(), results[1].Interface(), results[2].Bool()
+		}
+	}
+	return New(func(out chan<- interface{}) {
+		for {
+			v, next, ok := uf(seed)
+			if !ok {
+				return
+			}
+			out <- v
+			seed = next
+		}
+	})
+}
+
+// Repeat returns an infinite Pipeline emitting `v` over and over.
+func Repeat(v interface{}) Pipeline {
+	return New(func(out chan<- interface{}) {
+		for {
+			out <- v
+		}
+	})
+}
+
+// RepeatN returns a Pipeline emitting `v` `n` times.
+func RepeatN(v interface{}, n int) Pipeline {
+	return New(func(out chan<- interface{}) {
+		for i := 0; i < n; i++ {
+			out <- v
+		}
+	})
+}
+
+// Every emits the current time every `d` until `stop` fires, either by
+// receiving a value or by being closed. Ticks are dropped while the
+// consumer is slower than `d`.
+func Every(d time.Duration, stop <-chan struct{}) Pipeline {
+	return New(func(out chan<- interface{}) {
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for {
+			select {
+			case t := <-ticker.C:
+				select {
+				case out <- t:
+				case <-stop:
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	})
+}
+
+// Range returns a new Pipeline which contains
+// from start to end integer values.
+func Range(init int, r ...int) Pipeline {
+	switch len(r) {
+	case 0:
+		return RangeStep(0, init)
+	case 1:
+		return RangeStep(init, r[0])
+	case 2:
+		return RangeStep(init, r[0], r[1])
+	default:
+		return nil
+	}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// RangeStep returns a new Pipeline which contains
+// from start to end by step integer values.
+func RangeStep(start, end int, steps ...int) Pipeline {
+	step := 1
+	if len(steps) > 0 {
+		step = steps[0]
+	}
+	t := end - start
+	s := step * (t / abs(t))
+	return New(func(out chan<- interface{}) {
+		for i := 0; abs(i) < abs(t); i += s {
+			out <- start + i
+		}
+	})
+}
+
+// Lines reads contents line by line from reader and passes into pipeline.
+func Lines(r io.Reader, opts ...ReadOption) Pipeline {
+	return scanReader(r, bufio.ScanLines, opts)
+}
+
+// Words reads contents word by word from reader and passes into pipeline.
+func Words(r io.Reader, opts ...ReadOption) Pipeline {
+	return scanReader(r, bufio.ScanWords, opts)
+}
+
+// Runes reads contents rune by rune from reader and passes into
+// pipeline as `rune` values. Invalid UTF-8 yields utf8.RuneError.
+func Runes(r io.Reader, opts ...ReadOption) Pipeline {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanRunes)
+	return newReader(opts, func(out chan<- interface{}) error {
+		for scanner.Scan() {
+			r, _ := utf8.DecodeRune(scanner.Bytes())
+			out <- r
+		}
+		return scanner.Err()
+	})
+}
+
+// Chunks passes the content of r into pipeline as []byte blocks of the
+// given size; the last block may be shorter.
+func Chunks(r io.Reader, size int, opts ...ReadOption) Pipeline {
+	if size <= 0 {
+		panic("need positive chunk size")
+	}
+	return newReader(opts, func(out chan<- interface{}) error {
+		for {
+			buf := make([]byte, size)
+			n, err := io.ReadFull(r, buf)
+			if n > 0 {
+				out <- buf[:n]
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// FromStringRunes passes the runes of s into pipeline.
+func FromStringRunes(s string) Pipeline {
+	return Runes(strings.NewReader(s))
+}
+
+// FromStringWords passes the words of s into pipeline.
+func FromStringWords(s string) Pipeline {
+	return Words(strings.NewReader(s))
+}
+
+// FromStringLines passes the lines of s into pipeline.
+func FromStringLines(s string) Pipeline {
+	return Lines(strings.NewReader(s))
+}
+
+// FromRegexp reads contents line by line from reader and passes every
+// match of re into pipeline: the matched string, or a `[]string` of the
+// match and its submatches if re has capturing groups. Matches can't
+// span lines.
+func FromRegexp(re *regexp.Regexp, r io.Reader, opts ...ReadOption) Pipeline {
+	scanner := newScanner(r, opts)
+	return newReader(opts, func(out chan<- interface{}) error {
+		for scanner.Scan() {
+			line := scanner.Text()
+			if re.NumSubexp() == 0 {
+				for _, m := range re.FindAllString(line, -1) {
+					out <- m
+				}
+				continue
+			}
+			for _, m := range re.FindAllStringSubmatch(line, -1) {
+				out <- m
+			}
+		}
+		return scanner.Err()
+	})
+}
+
+// WalkOption configures WalkDir.
+type WalkOption func(*walker)
+
+type walker struct {
+	read      []ReadOption
+	exts      []string
+	skipDirs  []string
+	filesOnly bool
+	entries   bool
+}
+
+// WalkExt makes WalkDir emit only files with one of the extensions
+// `exts`, like ".go".
+func WalkExt(exts ...string) WalkOption {
+	return func(w *walker) {
+		w.exts = append(w.exts, exts...)
+	}
+}
+
+// WalkSkipDir makes WalkDir skip, without descending, the directories
+// with one of the base names `names`, like ".git".
+func WalkSkipDir(names ...string) WalkOption {
+	return func(w *walker) {
+		w.skipDirs = append(w.skipDirs, names...)
+	}
+}
+
+// WalkFilesOnly makes WalkDir leave directories out of its output.
+func WalkFilesOnly() WalkOption {
+	return func(w *walker) {
+		w.filesOnly = true
+	}
+}
+
+// WalkDirEntries makes WalkDir emit fs.DirEntry values instead of
+// paths.
+func WalkDirEntries() WalkOption {
+	return func(w *walker) {
+		w.entries = true
+	}
+}
+
+// WalkReadOptions applies the ReadOptions `opts` to WalkDir, e.g.
+// RecoverReadError.
+func WalkReadOptions(opts ...ReadOption) WalkOption {
+	return func(w *walker) {
+		w.read = append(w.read, opts...)
+	}
+}
+
+// WalkDir walks the file tree rooted at root with filepath.WalkDir and
+// passes the path of each file and directory into pipeline, in lexical
+// order. An error met while walking stops the Pipeline, and is passed
+// to RecoverReadError if given with WalkReadOptions.
+func WalkDir(root string, opts ...WalkOption) Pipeline {
+	var w walker
+	for _, opt := range opts {
+		opt(&w)
+	}
+	return newReader(w.read, func(out chan<- interface{}) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path != root && w.skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			if !w.match(d) {
+				return nil
+			}
+			if w.entries {
+				out <- d
+			} else {
+				out <- path
+			}
+			return nil
+		})
+	})
+}
+
+func (w *walker) skipDir(name string) bool {
+	for _, skip := range w.skipDirs {
+		if name == skip {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *walker) match(d fs.DirEntry) bool {
+	if d.IsDir() {
+		return !w.filesOnly && len(w.exts) == 0
+	}
+	if len(w.exts) == 0 {
+		return true
+	}
+	ext := filepath.Ext(d.Name())
+	for _, e := range w.exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// FromGlob passes the paths matching pattern into pipeline, in lexical
+// order. The pattern syntax is that of filepath.Match, plus a `**`
+// path segment matching zero or more directories, as in "src/**/*.go".
+// A malformed pattern stops the Pipeline, and is passed to
+// RecoverReadError.
+func FromGlob(pattern string, opts ...ReadOption) Pipeline {
+	return newReader(opts, func(out chan<- interface{}) error {
+		if !strings.Contains(pattern, "**") {
+			matches, err := filepath.Glob(pattern)
+			for _, m := range matches {
+				out <- m
+			}
+			return err
+		}
+		segs := strings.Split(filepath.ToSlash(pattern), "/")
+		i := 0
+		for i < len(segs)-1 && !strings.ContainsAny(segs[i], `*?[\`) {
+			i++
+		}
+		root := strings.Join(segs[:i], "/")
+		if root == "" && i > 0 {
+			root = "/"
+		} else if root == "" {
+			root = "."
+		}
+		root = filepath.FromSlash(root)
+		return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil || rel == "." {
+				return err
+			}
+			ok, err := matchSegments(segs[i:], strings.Split(filepath.ToSlash(rel), "/"))
+			if ok {
+				out <- p
+			}
+			return err
+		})
+	})
+}
+
+// matchSegments reports whether the path segments segs match the
+// pattern segments pat, where a `**` segment matches any number of
+// path segments.
+func matchSegments(pat, segs []string) (bool, error) {
+	if len(pat) == 0 {
+		return len(segs) == 0, nil
+	}
+	if pat[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if ok, err := matchSegments(pat[1:], segs[i:]); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	if len(segs) == 0 {
+		return false, nil
+	}
+	if ok, err := path.Match(pat[0], segs[0]); !ok || err != nil {
+		return false, err
+	}
+	return matchSegments(pat[1:], segs[1:])
+}
+
+func scanReader(r io.Reader, split bufio.SplitFunc, opts []ReadOption) Pipeline {
+	scanner := newScanner(r, opts)
+	scanner.Split(split)
+	return newReader(opts, func(out chan<- interface{}) error {
+		for scanner.Scan() {
+			out <- scanner.Text()
+		}
+		return scanner.Err()
+	})
+}
+
+// ReadOption configures a reader-backed source, like Lines or
+// FromJSONArray.
+type ReadOption func(*readConfig)
+
+type readConfig struct {
+	onErr   func(error) (interface{}, bool)
+	maxLine int
+}
+
+func newReadConfig(opts []ReadOption) readConfig {
+	c := readConfig{maxLine: bufio.MaxScanTokenSize}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// RecoverReadError makes a reader-backed source call f with the error
+// which stopped the underlying reader, after the elements read before
+// the failure. If f returns true, its value is emitted as the final
+// one. f is not called on clean EOF; without it the error is dropped.
+func RecoverReadError(f func(error) (interface{}, bool)) ReadOption {
+	return func(c *readConfig) {
+		c.onErr = f
+	}
+}
+
+// MaxLineSize makes line-based sources, like Lines and FromJSONLines,
+// accept lines up to `n` bytes instead of bufio.MaxScanTokenSize.
+// FromJSONLines skips a longer line and goes on, but the sources built
+// on bufio.Scanner, like Lines, Words, FromRegexp, FromHTTPStream and
+// FromSSE, stop at it with bufio.ErrTooLong, which RecoverReadError
+// sees; the lines after it are not read.
+func MaxLineSize(n int) ReadOption {
+	return func(c *readConfig) {
+		c.maxLine = n
+	}
+}
+
+// newScanner returns a bufio.Scanner reading r which accepts tokens up
+// to the MaxLineSize in opts.
+func newScanner(r io.Reader, opts []ReadOption) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, newReadConfig(opts).maxLine)
+	return scanner
+}
+
+// newReader is like New, but f returns the error which stopped the
+// underlying reader, if any, which is handed to RecoverReadError.
+func newReader(opts []ReadOption, f func(out chan<- interface{}) error) Pipeline {
+	c := newReadConfig(opts)
+	return New(func(out chan<- interface{}) {
+		if err := f(out); err != nil && c.onErr != nil {
+			if v, ok := c.onErr(err); ok {
+				out <- v
+			}
+		}
+	})
+}
+
+// FromJSONArray decodes the elements of a top-level JSON array from
+// reader one at a time, without reading the whole array first, and
+// passes them into pipeline. Each element is decoded into a new value of
+// the type of `proto`, or into interface{} if `proto` is nil. Malformed
+// input stops the Pipeline, and is passed to RecoverReadError.
+func FromJSONArray(r io.Reader, proto interface{}, opts ...ReadOption) Pipeline {
+	dec := json.NewDecoder(r)
+	return newReader(opts, func(out chan<- interface{}) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok != json.Delim('[') {
+			return fmt.Errorf("gofp: need JSON array, got %v", tok)
+		}
+		for dec.More() {
+			v, err := decodeJSON(dec.Decode, proto)
+			if err != nil {
+				return err
+			}
+			out <- v
+		}
+		_, err = dec.Token()
+		return err
+	})
+}
+
+// FromJSONLines decodes newline-delimited JSON from reader and passes
+// the values into pipeline, decoding each line into a new value of the
+// type of `proto`, or into interface{} if `proto` is nil. Blank lines
+// are skipped, and a line which fails to decode, or is longer than
+// MaxLineSize, is passed as an Err Result instead, so the rest of the
+// stream is still read.
+func FromJSONLines(r io.Reader, proto interface{}, opts ...ReadOption) Pipeline {
+	br := bufio.NewReader(r)
+	max := newReadConfig(opts).maxLine
+	return newReader(opts, func(out chan<- interface{}) error {
+		for n := 1; ; n++ {
+			line, err := readLine(br, max)
+			if err == bufio.ErrTooLong {
+				out <- Err(fmt.Errorf("gofp: line %d: %w", n, err))
+				continue
+			}
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if len(bytes.TrimSpace(line)) > 0 {
+				v, derr := decodeJSON(func(v interface{}) error {
+					return json.Unmarshal(line, v)
+				}, proto)
+				if derr != nil {
+					out <- Err(fmt.Errorf("gofp: line %d: %w", n, derr))
+				} else {
+					out <- v
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+		}
+	})
+}
+
+// readLine reads a line from br without its line ending. A line longer
+// than max bytes is skipped and reported as bufio.ErrTooLong.
+func readLine(br *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	n := 0
+	for {
+		chunk, err := br.ReadSlice('\n')
+		n += len(chunk)
+		if n <= max+2 {
+			line = append(line, chunk...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		if n > max+2 || len(line) > max {
+			return nil, bufio.ErrTooLong
+		}
+		return line, err
+	}
+}
+
+// CSVOption configures FromCSV.
+type CSVOption func(*csvSource)
+
+type csvSource struct {
+	read       []ReadOption
+	reader     *csv.Reader
+	skipHeader bool
+}
+
+// CSVComma sets the field delimiter of FromCSV, ',' by default.
+func CSVComma(comma rune) CSVOption {
+	return func(s *csvSource) {
+		s.reader.Comma = comma
+	}
+}
+
+// CSVComment makes FromCSV ignore lines beginning with `comment`.
+func CSVComment(comment rune) CSVOption {
+	return func(s *csvSource) {
+		s.reader.Comment = comment
+	}
+}
+
+// CSVSkipHeader makes FromCSV drop the first record.
+func CSVSkipHeader() CSVOption {
+	return func(s *csvSource) {
+		s.skipHeader = true
+	}
+}
+
+// CSVReadOptions applies the ReadOptions `opts` to FromCSV, e.g.
+// RecoverReadError.
+func CSVReadOptions(opts ...ReadOption) CSVOption {
+	return func(s *csvSource) {
+		s.read = append(s.read, opts...)
+	}
+}
+
+// FromCSV reads CSV records from reader with encoding/csv and passes
+// them into pipeline as `[]string`. Malformed input stops the Pipeline,
+// and is passed to RecoverReadError if given with CSVReadOptions.
+func FromCSV(r io.Reader, opts ...CSVOption) Pipeline {
+	s := csvSource{reader: csv.NewReader(r)}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return newReader(s.read, func(out chan<- interface{}) error {
+		for first := true; ; first = false {
+			record, err := s.reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !first || !s.skipHeader {
+				out <- record
+			}
+		}
+	})
+}
+
+// FromRows passes each row of rows into pipeline as a `[]interface{}`
+// of its column values, and closes rows when they are drained. Errors
+// met while iterating are passed to RecoverReadError.
+func FromRows(rows *sql.Rows, opts ...ReadOption) Pipeline {
+	return scanRows(rows, opts, func(cols []string, values []interface{}) interface{} {
+		return values
+	})
+}
+
+// FromRowsMap is like FromRows, but passes each row as a
+// `map[string]interface{}` keyed by column name.
+func FromRowsMap(rows *sql.Rows, opts ...ReadOption) Pipeline {
+	return scanRows(rows, opts, func(cols []string, values []interface{}) interface{} {
+		row := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			row[col] = values[i]
+		}
+		return row
+	})
+}
+
+func scanRows(rows *sql.Rows, opts []ReadOption, f func(cols []string, values []interface{}) interface{}) Pipeline {
+	return newReader(opts, func(out chan<- interface{}) error {
+		defer rows.Close()
+		cols, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			values := make([]interface{}, len(cols))
+			dest := make([]interface{}, len(cols))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return err
+			}
+			out <- f(cols, values)
+		}
+		return rows.Err()
+	})
+}
+
+// FromHTTPStream reads the body of a streaming HTTP response line by
+// line as it arrives and passes the lines into pipeline. The body is
+// closed once it's drained or fails, but stays open while the Pipeline
+// is not consumed to its end.
+func FromHTTPStream(resp *http.Response, opts ...ReadOption) Pipeline {
+	scanner := newScanner(resp.Body, opts)
+	return newReader(opts, func(out chan<- interface{}) error {
+		defer resp.Body.Close()
+		for scanner.Scan() {
+			out <- scanner.Text()
+		}
+		return scanner.Err()
+	})
+}
+
+// SSEEvent is an event of a Server-Sent Events stream.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// FromSSE parses the body of a Server-Sent Events response and passes
+// each event into pipeline as an SSEEvent. Multiple `data` lines of an
+// event are joined with "\n", comments are ignored, and events without
+// data are not emitted. As the SSE spec says, `ID` is the last event ID
+// seen, so it carries over to later events until an `id` field changes
+// it. The body is closed like FromHTTPStream does.
+func FromSSE(resp *http.Response, opts ...ReadOption) Pipeline {
+	scanner := newScanner(resp.Body, opts)
+	return newReader(opts, func(out chan<- interface{}) error {
+		defer resp.Body.Close()
+		var ev SSEEvent
+		var data []string
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				if len(data) > 0 {
+					ev.Data = strings.Join(data, "\n")
+					out <- ev
+				}
+				ev, data = SSEEvent{ID: ev.ID}, nil
+				continue
+			}
+			if strings.HasPrefix(line, ":") {
+				continue
+			}
+			field, value := line, ""
+			if i := strings.IndexByte(line, ':'); i >= 0 {
+				field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+			}
+			switch field {
+			case "id":
+				ev.ID = value
+			case "event":
+				ev.Event = value
+			case "data":
+				data = append(data, value)
+			case "retry":
+				if ms, err := strconv.Atoi(value); err == nil {
+					ev.Retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		}
+		return scanner.Err()
+	})
+}
+
+// decodeJSON calls decode with a pointer to a new value of the type of
+// proto, or to an interface{} if proto is nil, and returns the value.
+func decodeJSON(decode func(interface{}) error, proto interface{}) (interface{}, error) {
+	t := reflect.TypeOf(proto)
+	if t == nil {
+		var v interface{}
+		err := decode(&v)
+		return v, err
+	}
+	pv := reflect.New(t)
+	err := decode(pv.Interface())
+	return pv.Elem().Interface(), err
+}
+
+// TakeAll returns all values in Pipeline.
+func (pl Pipeline) TakeAll() []interface{} {
+	var values []interface{}
+	for v := range pl {
+		values = append(values, v)
+	}
+	return values
+}
+
+// Take takes the first `n` elements from Pipeline.
+func (pl Pipeline) Take(n int) []interface{} {
+	if n <= 0 {
+		return nil
+	}
+	var values []interface{}
+	for v := range pl {
+		values = append(values, v)
+		n--
+		if n <= 0 {
+			break
+		}
+	}
+	return values
+}
+
+// First takes the first element in Pipeline and returns.
+func (pl Pipeline) First() interface{} {
+	for v := range pl {
+		return v
+	}
+	return nil
+}
+
+// Last drains Pipeline and returns its last element, or nil if
+// Pipeline is empty.
+func (pl Pipeline) Last() interface{} {
+	var last interface{}
+	for v := range pl {
+		last = v
+	}
+	return last
+}
+
+// LastN drains Pipeline and returns its last `n` elements, keeping no
+// more than `n` of them in memory at any time.
+func (pl Pipeline) LastN(n int) []interface{} {
+	if n <= 0 {
+		pl.DropAll()
+		return nil
+	}
+	r := newRing(n)
+	for v := range pl {
+		r.push(v)
+	}
+	return r.values()
+}
+
+// Nth returns the `n`th element in Pipeline, counting from 1, and
+// whether Pipeline has that many elements. It reads no further than
+// the `n`th element.
+func (pl Pipeline) Nth(n int) (interface{}, bool) {
+	if n <= 0 {
+		return nil, false
+	}
+	for v := range pl {
+		if n--; n == 0 {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// TakeLast is the same as LastN.
+func (pl Pipeline) TakeLast(n int) []interface{} {
+	return pl.LastN(n)
+}
+
+// DropLast passes all but the last `n` elements in Pipeline through.
+// Only `n` elements are buffered at any time, so each element is
+// emitted once `n` newer ones have arrived.
+func (pl Pipeline) DropLast(n int) Pipeline {
+	return New(func(out chan<- interface{}) {
+		r := newRing(n)
+		for v := range pl {
+			if old, ok := r.shift(v); ok {
+				out <- old
+			}
+		}
+	})
+}
+
+// Drop ignores the first n elements in Pipeline and
+// returns itself.
+func (pl Pipeline) Drop(n int) Pipeline {
+	for i := 0; i < n; i++ {
+		<-pl
+	}
+	return pl
+}
+
+// DropAll drops all values in Pipeline.
+func (pl Pipeline) DropAll() {
+	for _ = range pl {
+	}
+}
+
+// Count drains Pipeline and returns the number of elements in it.
+func (pl Pipeline) Count() int {
+	n := 0
+	for range pl {
+		n++
+	}
+	return n
+}
+
+// Map passes each element in Pipeline into MapFunc.
+func (pl Pipeline) Map(f interface{}) Pipeline {
+	mf := toMapFunc(f)
+	return New(func(out chan<- interface{}) {
+		for i := range pl {
+			out <- mf.Map(i)
+		}
+	})
+}
+
+// Filter drops all the invalid elements in Pipeline.
+func (pl Pipeline) Filter(f interface{}) Pipeline {
+	ff := toFilterFunc(f)
+	return New(func(out chan<- interface{}) {
+		for i := range pl {
+			if ff.Filter(i) {
+				out <- i
+			}
+		}
+	})
+}
+
+// Reject drops the elements in Pipeline satisfying `pred`, the inverse
+// of Filter.
+func (pl Pipeline) Reject(pred interface{}) Pipeline {
+	return pl.Filter(toFilterFunc(pred).Not())
+}
+
+// Compact drops nil values, nil pointers and Nothing from Pipeline.
+func (pl Pipeline) Compact() Pipeline {
+	return pl.Reject(func(v interface{}) bool {
+		if v == nil || v == Nothing {
+			return true
+		}
+		rv := reflect.ValueOf(v)
+		return rv.Kind() == reflect.Ptr && rv.IsNil()
+	})
+}
+
+// Reduce reduces all elements in Pipeline to a final result.
+func (pl Pipeline) Reduce(f, init interface{}) interface{} {
+	rf := toReduceFunc(f)
+	result := init
+	for i := range pl {
+		result = rf.Reduce(i, result)
+	}
+	return result
+}
+
+// Flatten emits the contents of elements in Pipeline which are slices,
+// arrays or Pipelines instead of the containers themselves. Other
+// elements are passed through as-is. It's the same as FlattenDepth(1).
+func (pl Pipeline) Flatten() Pipeline {
+	return pl.FlattenDepth(1)
+}
+
+// FlattenDepth flattens nested slices, arrays and Pipelines in Pipeline
+// up to `depth` levels. Elements below the limit are passed through
+// as-is, and a `depth` <= 0 does no flattening at all.
+func (pl Pipeline) FlattenDepth(depth int) Pipeline {
+	return New(func(out chan<- interface{}) {
+		for v := range pl {
+			flattenInto(out, v, depth)
+		}
+	})
+}
+
+func flattenInto(out chan<- interface{}, v interface{}, depth int) {
+	if sub, ok := v.(Pipeline); ok && depth > 0 {
+		for x := range sub {
+			flattenInto(out, x, depth-1)
+		}
+		return
+	}
+	rv := reflect.ValueOf(v)
+	if depth <= 0 || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		out <- v
+		return
+	}
+	for i := 0; i < rv.Len(); i++ {
+		flattenInto(out, rv.Index(i).Interface(), depth-1)
+	}
+}
+
+// ReplayLast passes Pipeline through and keeps its last `n` elements.
+// The returned func creates a new Pipeline replaying a point-in-time
+// snapshot of those elements; elements seen later are not added to it.
+func (pl Pipeline) ReplayLast(n int) (Pipeline, func() Pipeline) {
+	var mu sync.Mutex
+	r := newRing(n)
+	live := New(func(out chan<- interface{}) {
+		for v := range pl {
+			mu.Lock()
+			r.push(v)
+			mu.Unlock()
+			out <- v
+		}
+	})
+	snapshot := func() Pipeline {
+		mu.Lock()
+		vs := r.values()
+		mu.Unlock()
+		return ForEach(vs...)
+	}
+	return live, snapshot
+}
+
+// ring is a fixed-size buffer holding the last values pushed into it.
+type ring struct {
+	buf  []interface{}
+	next int
+	full bool
+}
+
+func newRing(n int) *ring {
+	if n < 0 {
+		n = 0
+	}
+	return &ring{buf: make([]interface{}, n)}
+}
+
+func (r *ring) push(v interface{}) {
+	if len(r.buf) == 0 {
+		return
+	}
+	r.buf[r.next] = v
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// shift pushes v and returns the oldest value it evicts, if any.
+func (r *ring) shift(v interface{}) (interface{}, bool) {
+	if len(r.buf) == 0 {
+		return v, true
+	}
+	old, full := r.buf[r.next], r.full
+	r.push(v)
+	return old, full
+}
+
+// values returns a copy of the buffered values, oldest first.
+func (r *ring) values() []interface{} {
+	values := append([]interface{}(nil), r.buf[:r.next]...)
+	if r.full {
+		values = append(append([]interface{}(nil), r.buf[r.next:]...), values...)
+	}
+	return values
+}
+
+// SplitEvery groups Pipeline into `[]interface{}` chunks of exactly `n`
+// elements. A short final chunk is dropped, unless SplitPad is given to
+// fill it up to `n` elements. Without padding, for input whose length
+// is a multiple of `n`, SplitEvery(n).JoinEvery() yields the input
+// again; with padding, it does for any input once the trailing fill
+// elements are stripped.
+func (pl Pipeline) SplitEvery(n int, opts ...SplitOption) Pipeline {
+	if n <= 0 {
+		panic("need positive chunk size")
+	}
+	var s splitter
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return New(func(out chan<- interface{}) {
+		chunk := make([]interface{}, 0, n)
+		for v := range pl {
+			chunk = append(chunk, v)
+			if len(chunk) == n {
+				out <- chunk
+				chunk = make([]interface{}, 0, n)
+			}
+		}
+		if len(chunk) > 0 && s.pad {
+			for len(chunk) < n {
+				chunk = append(chunk, s.fill)
+			}
+			out <- chunk
+		}
+	})
+}
+
+// JoinEvery flattens `[]interface{}` chunks made by SplitEvery back
+// into single elements.
+func (pl Pipeline) JoinEvery() Pipeline {
+	return New(func(out chan<- interface{}) {
+		for chunk := range pl {
+			for _, v := range chunk.([]interface{}) {
+				out <- v
+			}
+		}
+	})
+}
+
+// Entry is a value annotated with its key, or with how many times
+// it occurs. Counting stages like RunLengthEncode set `Value` and
+// `Count`, grouping stages like GroupByOrdered set `Key` and `Value`.
+// More fields may be added, so build Entry with keyed literals.
+type Entry struct {
+	Key   interface{}
+	Value interface{}
+	Count int
+}
+
+// RunLengthEncode emits an Entry for each run of consecutive equal
+// elements in Pipeline, e.g. `a, a, b` yields `{a 2}, {b 1}`.
+// It panics on uncomparable elements.
+func (pl Pipeline) RunLengthEncode() Pipeline {
+	return New(func(out chan<- interface{}) {
+		var run *Entry
+		for v := range pl {
+			mustComparable(v)
+			if run != nil && run.Value == v {
+				run.Count++
+				continue
+			}
+			if run != nil {
+				out <- *run
+			}
+			run = &Entry{Value: v, Count: 1}
+		}
+		if run != nil {
+			out <- *run
+		}
+	})
+}
+
+// RunLengthDecode expands each Entry in Pipeline into `Count` copies
+// of its `Value`. It's the inverse of RunLengthEncode.
+func (pl Pipeline) RunLengthDecode() Pipeline {
+	return New(func(out chan<- interface{}) {
+		for v := range pl {
+			e := v.(Entry)
+			for i := 0; i < e.Count; i++ {
+				out <- e.Value
+			}
+		}
+	})
+}
+
+func mustComparable(v interface{}) {
+	if !isComparable(v) {
+		panic(fmt.Sprintf("uncomparable type %T", v))
+	}
+}
+
+// isComparable reports whether v can be compared with == without
+// panicking. Unlike reflect.Type.Comparable, it looks into the dynamic
+// values held in interface fields, e.g. a Pair of slices is not
+// comparable.
+func isComparable(v interface{}) bool {
+	return v == nil || comparableValue(reflect.ValueOf(v))
+}
+
+func comparableValue(v reflect.Value) bool {
+	if !v.Type().Comparable() {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		return v.IsNil() || comparableValue(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !comparableValue(v.Field(i)) {
+				return false
+			}
+		}
+	case reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Interface, reflect.Struct, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				if !comparableValue(v.Index(i)) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Prefetch eagerly pulls up to `n` elements ahead of the consumer into
+// an internal buffer from a dedicated goroutine, so a bursty producer and
+// a slow consumer don't stall each other. Memory is bounded by `n`
+// elements; if the consumer stops early the goroutine blocks once the
+// buffer is full, like every other stage.
+func (pl Pipeline) Prefetch(n int) Pipeline {
+	if n < 1 {
+		n = 1
+	}
+	out := make(chan interface{}, n)
+	go func() {
+		defer close(out)
+		for v := range pl {
+			out <- v
+		}
+	}()
+	return out
+}
+
+// MapReduce maps each element in Pipeline with `mapFn`, groups the
+// mapped values by `keyFn` applied to the original element, and reduces
+// each group to an accumulator starting from `init`. Like Reduce,
+// `reduceFn` is called as `reduceFn(value, accumulator)`, in the order
+// elements arrive.
+func (pl Pipeline) MapReduce(mapFn, keyFn, reduceFn, init interface{}) map[interface{}]interface{} {
+	mf := toMapFunc(mapFn)
+	kf := toMapFunc(keyFn)
+	rf := toReduceFunc(reduceFn)
+	results := make(map[interface{}]interface{})
+	for i := range pl {
+		k := kf.Map(i)
+		acc, ok := results[k]
+		if !ok {
+			acc = init
+		}
+		results[k] = rf.Reduce(mf.Map(i), acc)
+	}
+	return results
+}
+
+// DistinctWithCount emits an Entry for each distinct element in Pipeline,
+// in the order first seen, with `Count` being its total number of
+// occurrences. It has to drain Pipeline before emitting anything, and
+// panics on uncomparable elements.
+func (pl Pipeline) DistinctWithCount() Pipeline {
+	return New(func(out chan<- interface{}) {
+		var order []interface{}
+		counts := make(map[interface{}]int)
+		for v := range pl {
+			mustComparable(v)
+			if _, ok := counts[v]; !ok {
+				order = append(order, v)
+			}
+			counts[v]++
+		}
+		for _, v := range order {
+			out <- Entry{Value: v, Count: counts[v]}
+		}
+	})
+}
+
+// UnwrapAll consumes a Pipeline of *Result and returns the unwrapped
+// values. It stops at the first Err and returns the values before it
+// along with the error, abandoning the rest of Pipeline.
+func (pl Pipeline) UnwrapAll() ([]interface{}, error) {
+	var values []interface{}
+	for i := range pl {
+		v, err := i.(*Result).Unwrap()
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// SkipUntilSignal drops elements in Pipeline until `signal` fires, either
+// by receiving a value or by being closed, and then passes the rest
+// through. If Pipeline closes first, nothing is emitted.
+func (pl Pipeline) SkipUntilSignal(signal <-chan struct{}) Pipeline {
+	return New(func(out chan<- interface{}) {
+	skip:
+		for {
+			select {
+			case <-signal:
+				break skip
+			case v, ok := <-pl:
+				if !ok {
+					return
+				}
+				select {
+				case <-signal:
+					out <- v
+					break skip
+				default:
+				}
+			}
+		}
+		for v := range pl {
+			out <- v
+		}
+	})
+}
+
+// WeightedSampleReservoir draws `k` elements from Pipeline with
+// probability proportional to their weight, in a single pass using O(k)
+// memory. It implements the A-Res algorithm: each element gets the key
+// `u^(1/w)` with `u` uniform in [0, 1) from `rng` and `w` the positive
+// weight returned by `weightFn`, and the `k` largest keys are kept.
+// It panics on zero or negative weights.
+func (pl Pipeline) WeightedSampleReservoir(k int, weightFn interface{}, rng *rand.Rand) []interface{} {
+	if k <= 0 {
+		return nil
+	}
+	wf := toMapFunc(weightFn)
+	h := &keyedHeap{}
+	for v := range pl {
+		w := toFloat(wf.Map(v))
+		if w <= 0 {
+			panic(fmt.Sprintf("need positive weight, got %v", w))
+		}
+		key := math.Pow(rng.Float64(), 1/w)
+		if h.Len() < k {
+			heap.Push(h, keyed{key, v})
+		} else if key > (*h)[0].key {
+			(*h)[0] = keyed{key, v}
+			heap.Fix(h, 0)
+		}
+	}
+	values := make([]interface{}, h.Len())
+	for i := len(values) - 1; i >= 0; i-- {
+		values[i] = heap.Pop(h).(keyed).v
+	}
+	return values
+}
+
+type keyed struct {
+	key float64
+	v   interface{}
+}
+
+// keyedHeap is a min-heap of keyed values.
+type keyedHeap []keyed
+
+func (h keyedHeap) Len() int            { return len(h) }
+func (h keyedHeap) Less(i, j int) bool  { return h[i].key < h[j].key }
+func (h keyedHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *keyedHeap) Push(x interface{}) { *h = append(*h, x.(keyed)) }
+func (h *keyedHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// toFloat converts any integer or float value to float64.
+func toFloat(v interface{}) float64 {
+	rv := reflect.ValueOf(v)
+	switch {
+	case isInt(rv):
+		return float64(rv.Int())
+	case isUint(rv):
+		return float64(rv.Uint())
+	case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+		return rv.Float()
+	}
+	panic(fmt.Sprintf("non-numeric type %T", v))
+}
+
+// FirstWithin returns Just the first element in Pipeline if it arrives
+// within `d`, Nothing if Pipeline closes empty before that, or ErrTimeout
+// otherwise. On timeout the rest of Pipeline is abandoned.
+func (pl Pipeline) FirstWithin(d time.Duration) (*Maybe, error) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case v, ok := <-pl:
+		if !ok {
+			return Nothing, nil
+		}
+		return Just(v), nil
+	case <-timer.C:
+		return nil, ErrTimeout
+	}
+}
+
+// MergeMap maps each element in Pipeline to a sub-Pipeline with `f`,
+// drains up to `concurrency` sub-Pipelines at once and merges their
+// elements in the order they arrive, not the input order. Every
+// sub-Pipeline is drained completely before the output closes.
+func (pl Pipeline) MergeMap(concurrency int, f interface{}) Pipeline {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	mf := toMapFunc(f)
+	return New(func(out chan<- interface{}) {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+		for v := range pl {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(sub Pipeline) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				for v := range sub {
+					out <- v
+				}
+			}(toPipeline(mf.Map(v)))
+		}
+		wg.Wait()
+	})
+}
+
+// toPipeline converts v, which must be a Pipeline or any receivable
+// channel, to Pipeline.
+func toPipeline(v interface{}) Pipeline {
+	if pl, ok := v.(Pipeline); ok {
+		return pl
+	}
+	return FromChannel(v)
+}
+
+// ConcatMap maps each element in Pipeline to a sub-Pipeline with `f`
+// and drains the sub-Pipelines strictly one after another in input
+// order, so the output keeps the order of both.
+func (pl Pipeline) ConcatMap(f interface{}) Pipeline {
+	mf := toMapFunc(f)
+	return New(func(out chan<- interface{}) {
+		for v := range pl {
+			for x := range toPipeline(mf.Map(v)) {
+				out <- x
+			}
+		}
+	})
+}
+
+// SwitchMap maps each element in Pipeline to a sub-Pipeline with `f`
+// and forwards only the latest one: when a new element arrives, the
+// current sub-Pipeline is abandoned, so its output may be truncated.
+// Abandoned sub-Pipelines are drained in the background so they don't
+// block forever, which means an infinite one keeps running.
+func (pl Pipeline) SwitchMap(f interface{}) Pipeline {
+	mf := toMapFunc(f)
+	return New(func(out chan<- interface{}) {
+		src, cur := pl, Pipeline(nil)
+		for src != nil || cur != nil {
+			select {
+			case v, ok := <-src:
+				if !ok {
+					src = nil
+					continue
+				}
+				if cur != nil {
+					go cur.DropAll()
+				}
+				cur = toPipeline(mf.Map(v))
+			case v, ok := <-cur:
+				if !ok {
+					cur = nil
+					continue
+				}
+				out <- v
+			}
+		}
+	})
+}
+
+// Zip emits a Pair of corresponding elements from `a` and `b` until the
+// shorter of them is exhausted.
+func Zip(a, b Pipeline) Pipeline {
+	return New(func(out chan<- interface{}) {
+		for {
+			va, ok := <-a
+			if !ok {
+				return
+			}
+			vb, ok := <-b
+			if !ok {
+				return
+			}
+			out <- NewPair(va, vb)
+		}
+	})
+}
+
+// Zip is the method form of Zip(pl, other).
+func (pl Pipeline) Zip(other Pipeline) Pipeline {
+	return Zip(pl, other)
+}
+
+// ZipWith applies the two-argument func `f` to corresponding elements
+// from `a` and `b` until the shorter of them is exhausted.
+func ZipWith(a, b Pipeline, f interface{}) Pipeline {
+	zf := toReduceFunc(f)
+	return New(func(out chan<- interface{}) {
+		for p := range Zip(a, b) {
+			out <- zf.Reduce(p.(Pair).first, p.(Pair).second)
+		}
+	})
+}
+
+// Unzip splits a Pipeline of Pair into the Pipelines of their first and
+// second values, the inverse of Zip. Both sides buffer without bound so
+// each can be consumed independently.
+func Unzip(pl Pipeline) (Pipeline, Pipeline) {
+	firstIn, firsts := newQueue()
+	secondIn, seconds := newQueue()
+	go func() {
+		defer close(firstIn)
+		defer close(secondIn)
+		for v := range pl {
+			p := v.(Pair)
+			firstIn <- p.first
+			secondIn <- p.second
+		}
+	}()
+	return firsts, seconds
+}
+
+// Concat drains `pls` one after another in order.
+func Concat(pls ...Pipeline) Pipeline {
+	return New(func(out chan<- interface{}) {
+		for _, pl := range pls {
+			for v := range pl {
+				out <- v
+			}
+		}
+	})
+}
+
+// Merge reads from all of `pls` concurrently and emits their elements
+// as they arrive. The output closes after every input is closed.
+func Merge(pls ...Pipeline) Pipeline {
+	return New(func(out chan<- interface{}) {
+		var wg sync.WaitGroup
+		wg.Add(len(pls))
+		for _, pl := range pls {
+			go func(pl Pipeline) {
+				defer wg.Done()
+				for v := range pl {
+					out <- v
+				}
+			}(pl)
+		}
+		wg.Wait()
+	})
+}
+
+// Cross emits a Pair for every combination of elements from `a` and
+// `b`, in the order of `a` first. `b` is buffered entirely.
+func Cross(a, b Pipeline) Pipeline {
+	return New(func(out chan<- interface{}) {
+		bs := b.TakeAll()
+		for va := range a {
+			for _, vb := range bs {
+				out <- NewPair(va, vb)
+			}
+		}
+	})
+}
+
+// Union emits the distinct elements found in `a` or `b`, those of `a`
+// first.
+func Union(a, b Pipeline) Pipeline {
+	return Concat(a, b).Distinct()
+}
+
+// Intersect emits the distinct elements of `a` also found in `b`.
+// `b` is buffered entirely.
+func Intersect(a, b Pipeline) Pipeline {
+	return New(func(out chan<- interface{}) {
+		bs := newValueSet()
+		for v := range b {
+			bs.add(v)
+		}
+		for v := range a.Distinct() {
+			if bs.contains(v) {
+				out <- v
+			}
+		}
+	})
+}
+
+// Difference emits the distinct elements of `a` not found in `b`.
+// `b` is buffered entirely.
+func Difference(a, b Pipeline) Pipeline {
+	return New(func(out chan<- interface{}) {
+		bs := newValueSet()
+		for v := range b {
+			bs.add(v)
+		}
+		for v := range a.Distinct() {
+			if !bs.contains(v) {
+				out <- v
+			}
+		}
+	})
+}
+
+// Union is the method form of Union(pl, other).
+func (pl Pipeline) Union(other Pipeline) Pipeline {
+	return Union(pl, other)
+}
+
+// Intersect is the method form of Intersect(pl, other).
+func (pl Pipeline) Intersect(other Pipeline) Pipeline {
+	return Intersect(pl, other)
+}
+
+// Difference is the method form of Difference(pl, other).
+func (pl Pipeline) Difference(other Pipeline) Pipeline {
+	return Difference(pl, other)
+}
+
+// Interleave emits one element from each of `pls` in turn, skipping
+// the exhausted ones, until all are closed. Unlike Merge the order is
+// deterministic.
+func Interleave(pls ...Pipeline) Pipeline {
+	return New(func(out chan<- interface{}) {
+		active := append([]Pipeline(nil), pls...)
+		for len(active) > 0 {
+			next := active[:0]
+			for _, pl := range active {
+				if v, ok := <-pl; ok {
+					out <- v
+					next = append(next, pl)
+				}
+			}
+			active = next
+		}
+	})
+}
+
+// JoinOption configures Join.
+type JoinOption func(*joiner)
+
+type joiner struct {
+	left, right bool
+}
+
+// JoinLeft makes Join a left join: left elements without a match are
+// emitted paired with nil.
+func JoinLeft() JoinOption {
+	return func(j *joiner) {
+		j.left = true
+	}
+}
+
+// JoinOuter makes Join a full outer join: on top of JoinLeft, right
+// elements without a match are emitted last, paired after nil.
+func JoinOuter() JoinOption {
+	return func(j *joiner) {
+		j.left, j.right = true, true
+	}
+}
+
+// Join is a hash join of `left` and `right`, emitting a Pair of
+// (left, right) elements for each match of the keys derived by
+// `leftKey` and `rightKey`. `right` is buffered entirely, keys must be
+// comparable, and matches are emitted in left order, then right order.
+// It's an inner join unless JoinLeft or JoinOuter is given.
+func Join(left, right Pipeline, leftKey, rightKey interface{}, opts ...JoinOption) Pipeline {
+	lkf, rkf := toMapFunc(leftKey), toMapFunc(rightKey)
+	var j joiner
+	for _, opt := range opts {
+		opt(&j)
+	}
+	return New(func(out chan<- interface{}) {
+		rights := right.TakeAll()
+		index := make(map[interface{}][]int)
+		for i, r := range rights {
+			k := rkf.Map(r)
+			index[k] = append(index[k], i)
+		}
+		matched := make([]bool, len(rights))
+		for l := range left {
+			is := index[lkf.Map(l)]
+			for _, i := range is {
+				matched[i] = true
+				out <- NewPair(l, rights[i])
+			}
+			if len(is) == 0 && j.left {
+				out <- NewPair(l, nil)
+			}
+		}
+		for i, r := range rights {
+			if !matched[i] && j.right {
+				out <- NewPair(nil, r)
+			}
+		}
+	})
+}
+
+// CombineLatest emits a `[2]interface{}` holding the latest elements of
+// `a` and `b` whenever either of them emits. Nothing is emitted until
+// both have produced at least one element. The output closes once both
+// inputs are closed.
+func CombineLatest(a, b Pipeline) Pipeline {
+	return New(func(out chan<- interface{}) {
+		var latest [2]interface{}
+		var seenA, seenB bool
+		for a != nil || b != nil {
+			select {
+			case v, ok := <-a:
+				if !ok {
+					a = nil
+					continue
+				}
+				latest[0], seenA = v, true
+			case v, ok := <-b:
+				if !ok {
+					b = nil
+					continue
+				}
+				latest[1], seenB = v, true
+			}
+			if seenA && seenB {
+				out <- latest
+			}
+		}
+	})
+}
+
+// WithLatestFrom combines each element in Pipeline with the latest
+// element seen from `other` by calling `f(element, latest)`. Elements
+// arriving before `other` has produced anything are dropped. Reading
+// from `other` stops once Pipeline is closed.
+func (pl Pipeline) WithLatestFrom(other Pipeline, f interface{}) Pipeline {
+	cf := toReduceFunc(f)
+	return New(func(out chan<- interface{}) {
+		var latest interface{}
+		seen := false
+		for {
+			select {
+			case v, ok := <-pl:
+				if !ok {
+					return
+				}
+				if seen {
+					out <- cf.Reduce(v, latest)
+				}
+			case v, ok := <-other:
+				if !ok {
+					other = nil
+					continue
+				}
+				latest, seen = v, true
+			}
+		}
+	})
+}
+
+// BufferUntil collects elements in Pipeline into a `[]interface{}`
+// buffer and emits it each time a value arrives on `trigger`. A trigger
+// fired while the buffer is empty emits nothing. The final partial
+// buffer is emitted when Pipeline closes.
+func (pl Pipeline) BufferUntil(trigger <-chan struct{}) Pipeline {
+	return New(func(out chan<- interface{}) {
+		var buf []interface{}
+		for {
+			select {
+			case v, ok := <-pl:
+				if !ok {
+					if len(buf) > 0 {
+						out <- buf
+					}
+					return
+				}
+				buf = append(buf, v)
+			case _, ok := <-trigger:
+				if !ok {
+					trigger = nil
+					continue
+				}
+				if len(buf) > 0 {
+					out <- buf
+					buf = nil
+				}
+			}
+		}
+	})
+}
+
+// Segment splits Pipeline into `[]interface{}` segments, starting a new
+// segment at each element satisfying the `boundary` predicate. The
+// boundary element is the first one of the new segment, and elements
+// before the first boundary form a segment of their own.
+func (pl Pipeline) Segment(boundary interface{}) Pipeline {
+	ff := toFilterFunc(boundary)
+	return New(func(out chan<- interface{}) {
+		var seg []interface{}
+		for v := range pl {
+			if ff.Filter(v) && len(seg) > 0 {
+				out <- seg
+				seg = nil
+			}
+			seg = append(seg, v)
+		}
+		if len(seg) > 0 {
+			out <- seg
+		}
+	})
+}
+
+// Downsample groups every `factor` consecutive elements in Pipeline into
+// a `[]interface{}` and emits `aggFn` applied to each group. A final
+// group shorter than `factor` is aggregated and emitted as well.
+func (pl Pipeline) Downsample(factor int, aggFn interface{}) Pipeline {
+	if factor <= 0 {
+		panic("need positive factor")
+	}
+	af := toMapFunc(aggFn)
+	return New(func(out chan<- interface{}) {
+		group := make([]interface{}, 0, factor)
+		for v := range pl {
+			group = append(group, v)
+			if len(group) == factor {
+				out <- af.Map(group)
+				group = make([]interface{}, 0, factor)
+			}
+		}
+		if len(group) > 0 {
+			out <- af.Map(group)
+		}
+	})
+}
+
+// GroupByOrdered groups elements in Pipeline by the key `keyFn` returns,
+// and returns one Entry per key with `Value` holding the group as a
+// `[]interface{}`. Unlike a map, keys are in the order first seen.
+func (pl Pipeline) GroupByOrdered(keyFn interface{}) []Entry {
+	kf := toMapFunc(keyFn)
+	var keys []interface{}
+	groups := make(map[interface{}][]interface{})
+	for v := range pl {
+		k := kf.Map(v)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], v)
+	}
+	entries := make([]Entry, len(keys))
+	for i, k := range keys {
+		entries[i] = Entry{Key: k, Value: groups[k]}
+	}
+	return entries
+}
+
+// ExpandOption configures ExpandTree and ExpandTreeDFS.
+type ExpandOption func(*expander)
+
+// ExpandUnique makes the traversal skip nodes already visited, which
+// guards against cycles. Nodes must be comparable.
+func ExpandUnique() ExpandOption {
+	return func(e *expander) {
+		e.visited = make(map[interface{}]bool)
+	}
+}
+
+// ExpandMaxDepth stops expanding nodes `depth` levels below the seeds.
+func ExpandMaxDepth(depth int) ExpandOption {
+	return func(e *expander) {
+		e.maxDepth = depth
+	}
+}
+
+// ExpandTree treats each element in Pipeline as a node and emits all the
+// nodes reachable from them in breadth-first order, seeds first.
+// `childrenFn` returns a slice of the children of a node. A graph with
+// cycles expands forever unless ExpandUnique or ExpandMaxDepth is given.
+func (pl Pipeline) ExpandTree(childrenFn interface{}, opts ...ExpandOption) Pipeline {
+	e := newExpander(childrenFn, opts)
+	return New(func(out chan<- interface{}) {
+		var queue []treeNode
+		for v := range pl {
+			if e.visit(v) {
+				out <- v
+				queue = append(queue, treeNode{v, 0})
+			}
+		}
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			for _, c := range e.children(n) {
+				out <- c.v
+				queue = append(queue, c)
+			}
+		}
+	})
+}
+
+// ExpandTreeDFS is like ExpandTree, but emits the nodes in depth-first
+// pre-order, expanding each seed completely before the next one.
+func (pl Pipeline) ExpandTreeDFS(childrenFn interface{}, opts ...ExpandOption) Pipeline {
+	e := newExpander(childrenFn, opts)
+	return New(func(out chan<- interface{}) {
+		for v := range pl {
+			if !e.visit(v) {
+				continue
+			}
+			stack := []treeNode{{v, 0}}
+			for len(stack) > 0 {
+				n := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				out <- n.v
+				cs := e.children(n)
+				for i := len(cs) - 1; i >= 0; i-- {
+					stack = append(stack, cs[i])
+				}
+			}
+		}
+	})
+}
+
+type treeNode struct {
+	v     interface{}
+	depth int
+}
+
+type expander struct {
+	cf       MapFunc
+	visited  map[interface{}]bool
+	maxDepth int
+}
+
+func newExpander(childrenFn interface{}, opts []ExpandOption) *expander {
+	e := &expander{cf: toMapFunc(childrenFn), maxDepth: -1}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// visit reports whether node v should be traversed, marking it visited.
+func (e *expander) visit(v interface{}) bool {
+	if e.visited == nil {
+		return true
+	}
+	if e.visited[v] {
+		return false
+	}
+	e.visited[v] = true
+	return true
+}
+
+func (e *expander) children(n treeNode) []treeNode {
+	if e.maxDepth >= 0 && n.depth >= e.maxDepth {
+		return nil
+	}
+	cv := reflect.ValueOf(e.cf.Map(n.v))
+	var children []treeNode
+	for i := 0; cv.IsValid() && i < cv.Len(); i++ {
+		if c := cv.Index(i).Interface(); e.visit(c) {
+			children = append(children, treeNode{c, n.depth + 1})
+		}
+	}
+	return children
+}
+
+// latencySamples is how many of the latest intervals ObserveLatency keeps.
+const latencySamples = 1024
+
+// ObserveLatency passes Pipeline through and measures the intervals
+// between consecutive elements. The returned func reports the 50th, 95th
+// and 99th percentiles of a point-in-time snapshot of the intervals. The
+// percentiles are exact over the last 1024 intervals only, which bounds
+// the memory used; all zero is reported before two elements are seen.
+func (pl Pipeline) ObserveLatency() (Pipeline, func() (p50, p95, p99 time.Duration)) {
+	var mu sync.Mutex
+	r := newRing(latencySamples)
+	observed := New(func(out chan<- interface{}) {
+		var last time.Time
+		for v := range pl {
+			now := time.Now()
+			if !last.IsZero() {
+				mu.Lock()
+				r.push(now.Sub(last))
+				mu.Unlock()
+			}
+			last = now
+			out <- v
+		}
+	})
+	percentiles := func() (p50, p95, p99 time.Duration) {
+		mu.Lock()
+		samples := r.values()
+		mu.Unlock()
+		if len(samples) == 0 {
+			return
+		}
+		sort.Slice(samples, func(i, j int) bool {
+			return samples[i].(time.Duration) < samples[j].(time.Duration)
+		})
+		at := func(p float64) time.Duration {
+			return samples[int(p*float64(len(samples)-1))].(time.Duration)
+		}
+		return at(0.50), at(0.95), at(0.99)
+	}
+	return observed, percentiles
+}
+
+// FilterAccum filters Pipeline with a predicate which threads a state
+// through the elements. `f` is called as `f(state, element)` and returns
+// the new state and whether to keep the element; `init` is the state for
+// the first element.
+func (pl Pipeline) FilterAccum(f interface{}, init interface{}) Pipeline {
+	var af func(state, v interface{}) (interface{}, bool)
+	switch ft := f.(type) {
+	case func(interface{}, interface{}) (interface{}, bool):
+		af = ft
+	default:
+		af = func(state, v interface{}) (interface{}, bool) {
+			results := callAll(f, state, v)
+			return results[0].Interface(), results[1].Bool()
+		}
+	}
+	return New(func(out chan<- interface{}) {
+		state := init
+		for v := range pl {
+			var keep bool
+			if state, keep = af(state, v); keep {
+				out <- v
+			}
+		}
+	})
+}
+
+// MapFlat applies `f` to each element in Pipeline and flattens the
+// result one level if it's a slice, an array or a Pipeline, emitting
+// its contents. Any other result, including strings and maps, is
+// emitted as a single element.
+func (pl Pipeline) MapFlat(f interface{}) Pipeline {
+	mf := toMapFunc(f)
+	return New(func(out chan<- interface{}) {
+		for v := range pl {
+			if r := mf.Map(v); !emitFlat(out, r) {
+				out <- r
+			}
+		}
+	})
+}
+
+// emitFlat emits the contents of v if it's a slice, an array or a
+// Pipeline, and reports whether it did.
+func emitFlat(out chan<- interface{}, v interface{}) bool {
+	if sub, ok := v.(Pipeline); ok {
+		for x := range sub {
+			out <- x
+		}
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false
+	}
+	for i := 0; i < rv.Len(); i++ {
+		out <- rv.Index(i).Interface()
+	}
+	return true
+}
+
+// FlatMap applies `f` to each element in Pipeline and emits the
+// elements of each result one by one. `f` must return a slice, an
+// array or a Pipeline; unlike MapFlat, any other result panics.
+func (pl Pipeline) FlatMap(f interface{}) Pipeline {
+	mf := toMapFunc(f)
+	return New(func(out chan<- interface{}) {
+		for v := range pl {
+			if r := mf.Map(v); !emitFlat(out, r) {
+				panic(fmt.Sprintf("need slice, array or Pipeline, got %T", r))
+			}
+		}
+	})
+}
+
+// TakeWhile passes elements in Pipeline through until `pred` first
+// returns false, then closes the output and abandons the rest.
+func (pl Pipeline) TakeWhile(pred interface{}) Pipeline {
+	ff := toFilterFunc(pred)
+	return New(func(out chan<- interface{}) {
+		for v := range pl {
+			if !ff.Filter(v) {
+				return
+			}
+			out <- v
+		}
+	})
+}
+
+// DropWhile drops the leading elements in Pipeline as long as `pred`
+// returns true, then passes everything else through unchanged.
+func (pl Pipeline) DropWhile(pred interface{}) Pipeline {
+	ff := toFilterFunc(pred)
+	return New(func(out chan<- interface{}) {
+		dropping := true
+		for v := range pl {
+			if dropping && ff.Filter(v) {
+				continue
+			}
+			dropping = false
+			out <- v
+		}
+	})
+}
+
+// Distinct drops elements in Pipeline equal to one seen before.
+// Comparable elements are tracked in a map; uncomparable ones, like
+// slices or Pairs holding slices, fall back to a linear
+// reflect.DeepEqual scan over the uncomparable elements seen so far.
+func (pl Pipeline) Distinct() Pipeline {
+	return New(func(out chan<- interface{}) {
+		seen := newValueSet()
+		for v := range pl {
+			if seen.add(v) {
+				out <- v
+			}
+		}
+	})
+}
+
+// valueSet is a set of values which hashes comparable values and keeps
+// uncomparable ones in a list compared by reflect.DeepEqual.
+type valueSet struct {
+	hashed map[interface{}]bool
+	others []interface{}
+}
+
+func newValueSet() *valueSet {
+	return &valueSet{hashed: make(map[interface{}]bool)}
+}
+
+func (s *valueSet) contains(v interface{}) bool {
+	if isComparable(v) {
+		return s.hashed[v]
+	}
+	for _, o := range s.others {
+		if reflect.DeepEqual(o, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds v into valueSet and reports whether it was absent.
+func (s *valueSet) add(v interface{}) bool {
+	if s.contains(v) {
+		return false
+	}
+	if isComparable(v) {
+		s.hashed[v] = true
+	} else {
+		s.others = append(s.others, v)
+	}
+	return true
+}
+
+// Sorted drains Pipeline and returns its elements sorted by the
+// comparator `less`, e.g. `func(a, b int) bool { return a < b }`.
+// Equal elements keep their original order.
+func (pl Pipeline) Sorted(less interface{}) []interface{} {
+	lf := toLessFunc(less)
+	values := pl.TakeAll()
+	sort.SliceStable(values, func(i, j int) bool {
+		return lf(values[i], values[j])
+	})
+	return values
+}
+
+func toLessFunc(f interface{}) func(a, b interface{}) bool {
+	switch ft := f.(type) {
+	case func(interface{}, interface{}) bool:
+		return ft
+	default:
+		fn := NewFunc(f)
+		return func(a, b interface{}) bool {
+			return fn.Call(a, b).Bool()
+		}
+	}
+}
+
+// SortBy sorts Pipeline by the key `key` derives from each element and
+// emits the sorted elements. Keys must be numbers or strings, and
+// numbers sort before strings. It has to drain Pipeline before
+// emitting anything.
+func (pl Pipeline) SortBy(key interface{}) Pipeline {
+	kf := toMapFunc(key)
+	return New(func(out chan<- interface{}) {
+		var keys, values []interface{}
+		for v := range pl {
+			keys = append(keys, kf.Map(v))
+			values = append(values, v)
+		}
+		sort.Stable(byKey{keys, values})
+		for _, v := range values {
+			out <- v
+		}
+	})
+}
+
+type byKey struct {
+	keys, values []interface{}
+}
+
+func (s byKey) Len() int           { return len(s.keys) }
+func (s byKey) Less(i, j int) bool { return lessKey(s.keys[i], s.keys[j]) }
+func (s byKey) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
+
+// lessKey reports whether key a sorts before key b. Numbers compare by
+// value and strings lexically, and all numbers sort before all strings.
+// It panics on keys of any other kind.
+func lessKey(a, b interface{}) bool {
+	if ka, kb := keyKind(a), keyKind(b); ka != kb {
+		return ka < kb
+	}
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case av.Kind() == reflect.String:
+		return av.String() < bv.String()
+	case isInt(av) && isInt(bv):
+		return av.Int() < bv.Int()
+	case isUint(av) && isUint(bv):
+		return av.Uint() < bv.Uint()
+	}
+	return toFloat(a) < toFloat(b)
+}
+
+// keyKind returns 0 for a numeric key and 1 for a string key.
+func keyKind(k interface{}) int {
+	v := reflect.ValueOf(k)
+	switch {
+	case isInt(v), isUint(v), v.Kind() == reflect.Float32, v.Kind() == reflect.Float64:
+		return 0
+	case v.Kind() == reflect.String:
+		return 1
+	}
+	panic(fmt.Sprintf("unsortable key type %T", k))
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// Reverse drains Pipeline and emits its elements in reverse order.
+func (pl Pipeline) Reverse() Pipeline {
+	return New(func(out chan<- interface{}) {
+		values := pl.TakeAll()
+		for i := len(values) - 1; i >= 0; i-- {
+			out <- values[i]
+		}
+	})
+}
+
+// GroupBy drains Pipeline and groups its elements by the key `key`
+// derives from each of them.
+func (pl Pipeline) GroupBy(key interface{}) map[interface{}][]interface{} {
+	kf := toMapFunc(key)
+	groups := make(map[interface{}][]interface{})
+	for v := range pl {
+		k := kf.Map(v)
+		groups[k] = append(groups[k], v)
+	}
+	return groups
+}
+
+// GroupByTyped is like GroupBy, but the returned map is keyed by the
+// result type of `key`, e.g. `map[string][]interface{}` for a
+// `func(T) string`. Elements with a nil key, like a nil `error`, are
+// grouped under the zero value of the key type.
+func (pl Pipeline) GroupByTyped(key interface{}) interface{} {
+	kt := reflect.TypeOf((*interface{})(nil)).Elem()
+	switch key.(type) {
+	case Func, MapFunc, func(interface{}) interface{}:
+	default:
+		kt = reflect.TypeOf(key).Out(0)
+	}
+	groups := reflect.MakeMap(reflect.MapOf(kt, reflect.TypeOf([]interface{}(nil))))
+	for k, vs := range pl.GroupBy(key) {
+		kv := reflect.ValueOf(k)
+		if k == nil {
+			kv = reflect.Zero(kt)
+		}
+		groups.SetMapIndex(kv, reflect.ValueOf(vs))
+	}
+	return groups.Interface()
+}
+
+// Partition splits Pipeline into the elements satisfying `pred` and the
+// rest. Both sides buffer without bound, so each can be consumed
+// independently without blocking the other, at the cost of memory when
+// one side lags behind.
+func (pl Pipeline) Partition(pred interface{}) (matched, rest Pipeline) {
+	ff := toFilterFunc(pred)
+	matchedIn, matched := newQueue()
+	restIn, rest := newQueue()
+	go func() {
+		defer close(matchedIn)
+		defer close(restIn)
+		for v := range pl {
+			if ff.Filter(v) {
+				matchedIn <- v
+			} else {
+				restIn <- v
+			}
+		}
+	}()
+	return matched, rest
+}
+
+// newQueue returns a channel and a Pipeline emitting whatever is sent
+// into it. Elements are buffered without bound, so a send never waits
+// for the consumer. Closing the channel closes Pipeline once drained.
+func newQueue() (chan<- interface{}, Pipeline) {
+	ch := make(chan interface{})
+	return ch, New(func(out chan<- interface{}) {
+		in := ch
+		var buf []interface{}
+		for in != nil || len(buf) > 0 {
+			var send chan<- interface{}
+			var next interface{}
+			if len(buf) > 0 {
+				send, next = out, buf[0]
+			}
+			select {
+			case v, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				buf = append(buf, v)
+			case send <- next:
+				buf = buf[1:]
+			}
+		}
+	})
+}
+
+// Chunk groups Pipeline into `[]interface{}` batches of `n` elements.
+// Unlike SplitEvery, a short final batch is emitted as well.
+func (pl Pipeline) Chunk(n int) Pipeline {
+	if n <= 0 {
+		panic("need positive chunk size")
+	}
+	return New(func(out chan<- interface{}) {
+		chunk := make([]interface{}, 0, n)
+		for v := range pl {
+			chunk = append(chunk, v)
+			if len(chunk) == n {
+				out <- chunk
+				chunk = make([]interface{}, 0, n)
+			}
+		}
+		if len(chunk) > 0 {
+			out <- chunk
+		}
+	})
+}
+
+// Window emits `[]interface{}` windows of `size` consecutive elements
+// in Pipeline, starting a new window every `step` elements, so windows
+// overlap when `step` < `size`. Trailing elements not filling a whole
+// window are not emitted.
+func (pl Pipeline) Window(size, step int) Pipeline {
+	if size <= 0 || step <= 0 {
+		panic("need positive window size and step")
+	}
+	return New(func(out chan<- interface{}) {
+		win := make([]interface{}, 0, size)
+		skip := 0
+		for v := range pl {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			win = append(win, v)
+			if len(win) < size {
+				continue
+			}
+			out <- append([]interface{}(nil), win...)
+			if step >= size {
+				skip = step - size
+				win = win[:0]
+			} else {
+				win = win[:copy(win, win[step:])]
+			}
+		}
+	})
+}
+
+// Scan is like Reduce, but emits every intermediate result.
+func (pl Pipeline) Scan(f, init interface{}) Pipeline {
+	rf := toReduceFunc(f)
+	return New(func(out chan<- interface{}) {
+		result := init
+		for i := range pl {
+			result = rf.Reduce(i, result)
+			out <- result
+		}
+	})
+}
+
+// Tap calls `f` with each element in Pipeline and passes the element
+// through untouched. Any result of `f` is ignored.
+func (pl Pipeline) Tap(f interface{}) Pipeline {
+	tf, ok := f.(func(interface{}))
+	if !ok {
+		tf = func(v interface{}) {
+			callAll(f, v)
+		}
+	}
+	return New(func(out chan<- interface{}) {
+		for v := range pl {
+			tf(v)
+			out <- v
+		}
+	})
+}
+
+// Sum drains Pipeline and returns the sum of its numeric elements as
+// float64.
+func (pl Pipeline) Sum() float64 {
+	sum := 0.0
+	for v := range pl {
+		sum += toFloat(v)
+	}
+	return sum
+}
+
+// Min drains Pipeline and returns its smallest numeric element, or nil
+// if Pipeline is empty.
+func (pl Pipeline) Min() interface{} {
+	var min interface{}
+	for v := range pl {
+		if min == nil || lessKey(v, min) {
+			min = v
+		}
+	}
+	return min
+}
+
+// Max drains Pipeline and returns its largest numeric element, or nil
+// if Pipeline is empty.
+func (pl Pipeline) Max() interface{} {
+	var max interface{}
+	for v := range pl {
+		if max == nil || lessKey(max, v) {
+			max = v
+		}
+	}
+	return max
+}
+
+// Stats holds summary statistics of numbers.
+type Stats struct {
+	Count    int
+	Sum      float64
+	Mean     float64
+	Min      float64
+	Max      float64
+	Variance float64
+	StdDev   float64
+}
+
+// Stats drains Pipeline and computes the statistics of its numeric
+// elements in a single pass using Welford's algorithm. Variance is the
+// population variance. An empty Pipeline yields zero Stats.
+func (pl Pipeline) Stats() Stats {
+	var s Stats
+	var m2 float64
+	for v := range pl {
+		x := toFloat(v)
+		s.Count++
+		s.Sum += x
+		if s.Count == 1 || x < s.Min {
+			s.Min = x
+		}
+		if s.Count == 1 || x > s.Max {
+			s.Max = x
+		}
+		delta := x - s.Mean
+		s.Mean += delta / float64(s.Count)
+		m2 += delta * (x - s.Mean)
+	}
+	if s.Count > 0 {
+		s.Variance = m2 / float64(s.Count)
+		s.StdDev = math.Sqrt(s.Variance)
+	}
+	return s
+}
+
+// Any reports whether any element in Pipeline satisfies `pred`. It
+// stops reading at the first one that does.
+func (pl Pipeline) Any(pred interface{}) bool {
+	ff := toFilterFunc(pred)
+	for v := range pl {
+		if ff.Filter(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// All reports whether every element in Pipeline satisfies `pred`. It
+// stops reading at the first one that doesn't.
+func (pl Pipeline) All(pred interface{}) bool {
+	return !pl.Any(toFilterFunc(pred).Not())
+}
+
+// None reports whether no element in Pipeline satisfies `pred`. It
+// stops reading at the first one that does.
+func (pl Pipeline) None(pred interface{}) bool {
+	return !pl.Any(pred)
+}
+
+// Contains reports whether Pipeline has an element equal to `v`, using
+// reflect.DeepEqual for uncomparable values. It stops reading at the
+// first match.
+func (pl Pipeline) Contains(v interface{}) bool {
+	for i := range pl {
+		if equal(i, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares a and b with ==, or with reflect.DeepEqual if they're
+// uncomparable. If a is comparable all the way down, == can't panic
+// whatever b holds.
+func equal(a, b interface{}) bool {
+	if isComparable(a) {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Find returns the first element in Pipeline satisfying `pred` and
+// whether there is one. It stops reading at the first match.
+func (pl Pipeline) Find(pred interface{}) (interface{}, bool) {
+	ff := toFilterFunc(pred)
+	for v := range pl {
+		if ff.Filter(v) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Indexed is an element annotated with its position in a Pipeline.
+type Indexed struct {
+	Index int
+	Value interface{}
+}
+
+// Enumerate wraps each element in Pipeline in an Indexed, counting
+// from 0.
+func (pl Pipeline) Enumerate() Pipeline {
+	return New(func(out chan<- interface{}) {
+		i := 0
+		for v := range pl {
+			out <- Indexed{Index: i, Value: v}
+			i++
+		}
+	})
+}
+
+// Tee duplicates each element in Pipeline into `n` independent
+// Pipelines. Each of them buffers without bound, so a slow consumer
+// doesn't block the others, at the cost of memory while it lags.
+func (pl Pipeline) Tee(n int) []Pipeline {
+	ins := make([]chan<- interface{}, n)
+	outs := make([]Pipeline, n)
+	for i := range outs {
+		ins[i], outs[i] = newQueue()
+	}
+	go func() {
+		for v := range pl {
+			for _, in := range ins {
+				in <- v
+			}
+		}
+		for _, in := range ins {
+			close(in)
+		}
+	}()
+	return outs
+}
+
+// Buffered inserts a buffered channel of capacity `n` between Pipeline
+// and its consumer, so a bursty upstream doesn't stall on a slow
+// downstream stage. It's the same stage as Prefetch.
+func (pl Pipeline) Buffered(n int) Pipeline {
+	return pl.Prefetch(n)
+}
+
+// Throttle limits Pipeline to pass at most `n` elements per `per` on
+// average, allowing bursts of up to `n` elements after idling. It's a
+// token bucket refilled at a steady rate.
+func (pl Pipeline) Throttle(n int, per time.Duration) Pipeline {
+	if n <= 0 || per <= 0 {
+		panic("need positive rate")
+	}
+	interval := float64(per) / float64(n)
+	return New(func(out chan<- interface{}) {
+		tokens, last := float64(n), time.Now()
+		for v := range pl {
+			now := time.Now()
+			tokens = math.Min(float64(n), tokens+float64(now.Sub(last))/interval)
+			last = now
+			if tokens < 1 {
+				time.Sleep(time.Duration((1 - tokens) * interval))
+				tokens, last = 1, time.Now()
+			}
+			tokens--
+			out <- v
+		}
+	})
+}
+
+// Debounce emits an element in Pipeline only if no newer one arrives
+// within `d`, so each burst of elements yields its last one. A pending
+// element is emitted at once when Pipeline closes.
+func (pl Pipeline) Debounce(d time.Duration) Pipeline {
+	return New(func(out chan<- interface{}) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		stopTimer := func() {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+		stopTimer()
+		var pending interface{}
+		var fire <-chan time.Time
+		for {
+			select {
+			case v, ok := <-pl:
+				if !ok {
+					if fire != nil {
+						out <- pending
+					}
+					return
+				}
+				stopTimer()
+				timer.Reset(d)
+				pending, fire = v, timer.C
+			case <-fire:
+				out <- pending
+				pending, fire = nil, nil
+			}
+		}
+	})
+}
+
+// Sample emits every `n`th element in Pipeline, i.e. the `n`th, the
+// `2n`th and so on.
+func (pl Pipeline) Sample(n int) Pipeline {
+	if n <= 0 {
+		panic("need positive sample interval")
+	}
+	return New(func(out chan<- interface{}) {
+		i := 0
+		for v := range pl {
+			if i++; i == n {
+				out <- v
+				i = 0
+			}
+		}
+	})
+}
+
+// SampleP keeps each element in Pipeline independently with
+// probability `p`, using the default source of math/rand.
+func (pl Pipeline) SampleP(p float64) Pipeline {
+	return New(func(out chan<- interface{}) {
+		for v := range pl {
+			if rand.Float64() < p {
+				out <- v
+			}
+		}
+	})
+}
+
+// Cycle emits the elements in Pipeline `times` times over, or forever
+// if `times` <= 0. The first round streams while buffering elements for
+// the rest. An empty Pipeline yields an empty one.
+func (pl Pipeline) Cycle(times int) Pipeline {
+	return New(func(out chan<- interface{}) {
+		var values []interface{}
+		for v := range pl {
+			values = append(values, v)
+			out <- v
+		}
+		if len(values) == 0 {
+			return
+		}
+		for i := 1; times <= 0 || i < times; i++ {
+			for _, v := range values {
+				out <- v
+			}
+		}
+	})
 }
 
-// ForEach creates a new Pipeline instances.
-func ForEach(vs ...interface{}) Pipeline {
-	return New(func(ch chan<- interface{}) {
-		for _, v := range vs {
-			ch <- v
+// Intersperse emits `sep` between each two consecutive elements in
+// Pipeline.
+func (pl Pipeline) Intersperse(sep interface{}) Pipeline {
+	return New(func(out chan<- interface{}) {
+		first := true
+		for v := range pl {
+			if !first {
+				out <- sep
+			}
+			first = false
+			out <- v
 		}
 	})
 }
 
-// FromArray new Pipeline from any array or slice.
-func FromArray(array interface{}) Pipeline {
-	at := reflect.TypeOf(array)
-	av := reflect.ValueOf(array)
-	if at.Kind() != reflect.Array && at.Kind() != reflect.Slice {
-		panic("need slice or array")
+// JoinStrings drains Pipeline and joins its elements with `sep`.
+// Elements which are not strings are formatted with fmt.Sprint.
+func (pl Pipeline) JoinStrings(sep string) string {
+	var b strings.Builder
+	first := true
+	for v := range pl {
+		if !first {
+			b.WriteString(sep)
+		}
+		first = false
+		if s, ok := v.(string); ok {
+			b.WriteString(s)
+		} else {
+			fmt.Fprint(&b, v)
+		}
 	}
-	return New(func(ch chan<- interface{}) {
-		for i := 0; i < av.Len(); i++ {
-			ch <- av.Index(i).Interface()
+	return b.String()
+}
+
+// Pairwise emits a Pair of (previous, current) for each element in
+// Pipeline after the first.
+func (pl Pipeline) Pairwise() Pipeline {
+	return New(func(out chan<- interface{}) {
+		prev, ok := <-pl
+		if !ok {
+			return
+		}
+		for v := range pl {
+			out <- NewPair(prev, v)
+			prev = v
 		}
 	})
 }
 
-// Range returns a new Pipeline which contains
-// from start to end integer values.
-func Range(init int, r ...int) Pipeline {
-	switch len(r) {
-	case 0:
-		return RangeStep(0, init)
-	case 1:
-		return RangeStep(init, r[0])
-	case 2:
-		return RangeStep(init, r[0], r[1])
-	default:
-		return nil
+// DedupConsecutive drops each element in Pipeline equal to the one
+// right before it, like Unix `uniq`. It compares with == or
+// reflect.DeepEqual for uncomparable values, and keeps only the last
+// element in memory.
+func (pl Pipeline) DedupConsecutive() Pipeline {
+	return New(func(out chan<- interface{}) {
+		prev, ok := <-pl
+		if !ok {
+			return
+		}
+		out <- prev
+		for v := range pl {
+			if !equal(v, prev) {
+				out <- v
+			}
+			prev = v
+		}
+	})
+}
+
+// MapIndexed is like Map, but calls `f(index, element)` with the
+// position of each element, counting from 0.
+func (pl Pipeline) MapIndexed(f interface{}) Pipeline {
+	mf := toReduceFunc(f)
+	return New(func(out chan<- interface{}) {
+		i := 0
+		for v := range pl {
+			out <- mf.Reduce(i, v)
+			i++
+		}
+	})
+}
+
+// FilterIndexed is like Filter, but calls `f(index, element)` with the
+// position of each element, counting from 0, e.g.
+// `func(i int, v T) bool`.
+func (pl Pipeline) FilterIndexed(f interface{}) Pipeline {
+	ff, ok := f.(func(int, interface{}) bool)
+	if !ok {
+		fn := NewFunc(f)
+		ff = func(i int, v interface{}) bool {
+			return fn.Call(i, v).Bool()
+		}
 	}
+	return New(func(out chan<- interface{}) {
+		i := 0
+		for v := range pl {
+			if ff(i, v) {
+				out <- v
+			}
+			i++
+		}
+	})
 }
 
-func abs(i int) int {
-	if i < 0 {
-		return -i
+// Shuffle drains Pipeline and emits its elements in a random order,
+// shuffled by Fisher–Yates with randomness from `src`. The same source
+// state yields the same order.
+func (pl Pipeline) Shuffle(src rand.Source) Pipeline {
+	r := rand.New(src)
+	return New(func(out chan<- interface{}) {
+		values := pl.TakeAll()
+		for i := len(values) - 1; i > 0; i-- {
+			j := r.Intn(i + 1)
+			values[i], values[j] = values[j], values[i]
+		}
+		for _, v := range values {
+			out <- v
+		}
+	})
+}
+
+// MinBy drains Pipeline and returns the element with the smallest key
+// derived by `key`, or nil if Pipeline is empty. Keys are ordered
+// like in SortBy; the first of equal elements wins.
+func (pl Pipeline) MinBy(key interface{}) interface{} {
+	return pl.extremeBy(key, lessKey)
+}
+
+// MaxBy drains Pipeline and returns the element with the largest key
+// derived by `key`, or nil if Pipeline is empty. Keys are ordered
+// like in SortBy; the first of equal elements wins.
+func (pl Pipeline) MaxBy(key interface{}) interface{} {
+	return pl.extremeBy(key, func(a, b interface{}) bool {
+		return lessKey(b, a)
+	})
+}
+
+// extremeBy returns the element whose key comes first by `before`.
+func (pl Pipeline) extremeBy(key interface{}, before func(a, b interface{}) bool) interface{} {
+	kf := toMapFunc(key)
+	var best, bestKey interface{}
+	found := false
+	for v := range pl {
+		if k := kf.Map(v); !found || before(k, bestKey) {
+			best, bestKey, found = v, k, true
+		}
 	}
-	return i
+	return best
 }
 
-// RangeStep returns a new Pipeline which contains
-// from start to end by step integer values.
-func RangeStep(start, end int, steps ...int) Pipeline {
-	step := 1
-	if len(steps) > 0 {
-		step = steps[0]
+// FoldRight is like Reduce, but folds from the last element to the
+// first, so `f(e1, f(e2, ... f(en, init)))` is returned. It has to
+// buffer all elements in Pipeline.
+func (pl Pipeline) FoldRight(f, init interface{}) interface{} {
+	rf := toReduceFunc(f)
+	values := pl.TakeAll()
+	result := init
+	for i := len(values) - 1; i >= 0; i-- {
+		result = rf.Reduce(values[i], result)
 	}
-	t := end - start
-	s := step * (t / abs(t))
+	return result
+}
+
+// GroupAdjacent emits a `[]interface{}` group of consecutive elements
+// in Pipeline each time the key derived by `key` changes. For input
+// sorted by key it groups like GroupBy, holding one group in memory.
+func (pl Pipeline) GroupAdjacent(key interface{}) Pipeline {
+	return pl.ChunkByKey(key).Map(func(e Entry) interface{} {
+		return e.Value
+	})
+}
+
+// ChunkByKey is like GroupAdjacent, but emits each group as an Entry
+// with the shared key in `Key` and the group in `Value`. Assuming
+// Pipeline is sorted by key, it's a streaming GroupBy which never holds
+// more than one group in memory.
+func (pl Pipeline) ChunkByKey(key interface{}) Pipeline {
+	kf := toMapFunc(key)
 	return New(func(out chan<- interface{}) {
-		for i := 0; abs(i) < abs(t); i += s {
-			out <- start + i
+		var group []interface{}
+		var groupKey interface{}
+		for v := range pl {
+			k := kf.Map(v)
+			if len(group) > 0 && !equal(k, groupKey) {
+				out <- Entry{Key: groupKey, Value: group}
+				group = nil
+			}
+			group, groupKey = append(group, v), k
+		}
+		if len(group) > 0 {
+			out <- Entry{Key: groupKey, Value: group}
 		}
 	})
 }
 
-// Lines reads contents line by line from reader and passes into pipeline.
-func Lines(r io.Reader) Pipeline {
-	return scanReader(r, bufio.ScanLines)
+// SplitOption configures SplitWhen and SplitEvery.
+type SplitOption func(*splitter)
+
+type splitter struct {
+	keep bool
+	pad  bool
+	fill interface{}
 }
 
-// Words reads contents word by word from reader and passes into pipeline.
-func Words(r io.Reader) Pipeline {
-	return scanReader(r, bufio.ScanWords)
+// SplitKeepDelimiter makes SplitWhen keep each delimiter as the last
+// element of the piece it ends.
+func SplitKeepDelimiter() SplitOption {
+	return func(s *splitter) {
+		s.keep = true
+	}
 }
 
-func scanReader(r io.Reader, split bufio.SplitFunc) Pipeline {
-	scanner := bufio.NewScanner(r)
-	scanner.Split(split)
+// SplitPad makes SplitEvery emit a short final chunk too, padded with
+// `fill` up to the chunk size.
+func SplitPad(fill interface{}) SplitOption {
+	return func(s *splitter) {
+		s.pad, s.fill = true, fill
+	}
+}
+
+// SplitWhen splits Pipeline into `[]interface{}` pieces separated by
+// the elements satisfying `pred`. Delimiters are dropped unless
+// SplitKeepDelimiter is given, and empty pieces are not emitted.
+func (pl Pipeline) SplitWhen(pred interface{}, opts ...SplitOption) Pipeline {
+	ff := toFilterFunc(pred)
+	var s splitter
+	for _, opt := range opts {
+		opt(&s)
+	}
 	return New(func(out chan<- interface{}) {
-		for scanner.Scan() {
-			out <- scanner.Text()
+		var piece []interface{}
+		for v := range pl {
+			if !ff.Filter(v) {
+				piece = append(piece, v)
+				continue
+			}
+			if s.keep {
+				piece = append(piece, v)
+			}
+			if len(piece) > 0 {
+				out <- piece
+				piece = nil
+			}
+		}
+		if len(piece) > 0 {
+			out <- piece
 		}
 	})
 }
 
-// TakeAll returns all values in Pipeline.
-func (pl Pipeline) TakeAll() []interface{} {
-	var values []interface{}
+// CountBy drains Pipeline and counts its elements by the key derived
+// by `key`. A nil `key` counts the elements themselves.
+func (pl Pipeline) CountBy(key interface{}) map[interface{}]int {
+	kf := MapFunc(func(v interface{}) interface{} { return v })
+	if key != nil {
+		kf = toMapFunc(key)
+	}
+	counts := make(map[interface{}]int)
 	for v := range pl {
-		values = append(values, v)
+		counts[kf.Map(v)]++
 	}
-	return values
+	return counts
 }
 
-// Take takes the first `n` elements from Pipeline.
-func (pl Pipeline) Take(n int) []interface{} {
+// TopN drains Pipeline and returns its `n` largest elements by the
+// comparator `less`, largest first. It keeps only `n` elements in a
+// heap, so memory is O(n) whatever the length of Pipeline.
+func (pl Pipeline) TopN(n int, less interface{}) []interface{} {
 	if n <= 0 {
+		pl.DropAll()
 		return nil
 	}
-	var values []interface{}
+	h := &lessHeap{less: toLessFunc(less)}
 	for v := range pl {
-		values = append(values, v)
-		n--
-		if n <= 0 {
-			break
+		if h.Len() < n {
+			heap.Push(h, v)
+		} else if h.less(h.values[0], v) {
+			h.values[0] = v
+			heap.Fix(h, 0)
 		}
 	}
+	values := make([]interface{}, h.Len())
+	for i := len(values) - 1; i >= 0; i-- {
+		values[i] = heap.Pop(h)
+	}
 	return values
 }
 
-// First takes the first element in Pipeline and returns.
-func (pl Pipeline) First() interface{} {
+// lessHeap is a min-heap of values ordered by less.
+type lessHeap struct {
+	values []interface{}
+	less   func(a, b interface{}) bool
+}
+
+func (h lessHeap) Len() int            { return len(h.values) }
+func (h lessHeap) Less(i, j int) bool  { return h.less(h.values[i], h.values[j]) }
+func (h lessHeap) Swap(i, j int)       { h.values[i], h.values[j] = h.values[j], h.values[i] }
+func (h *lessHeap) Push(x interface{}) { h.values = append(h.values, x) }
+func (h *lessHeap) Pop() interface{} {
+	x := h.values[len(h.values)-1]
+	h.values = h.values[:len(h.values)-1]
+	return x
+}
+
+// Percentiles drains Pipeline and returns the exact percentiles `ps`,
+// each in [0, 100], of its numeric elements, interpolating linearly
+// between the closest ranks. An empty Pipeline yields an empty map.
+func (pl Pipeline) Percentiles(ps ...float64) map[float64]float64 {
+	for _, p := range ps {
+		if p < 0 || p > 100 {
+			panic(fmt.Sprintf("need percentile in [0, 100], got %v", p))
+		}
+	}
+	var xs []float64
 	for v := range pl {
-		return v
+		xs = append(xs, toFloat(v))
 	}
-	return nil
+	results := make(map[float64]float64, len(ps))
+	if len(xs) == 0 {
+		return results
+	}
+	sort.Float64s(xs)
+	for _, p := range ps {
+		rank := p / 100 * float64(len(xs)-1)
+		lo := int(rank)
+		if lo == len(xs)-1 {
+			results[p] = xs[lo]
+			continue
+		}
+		results[p] = xs[lo] + (rank-float64(lo))*(xs[lo+1]-xs[lo])
+	}
+	return results
 }
 
-// Drop ignores the first n elements in Pipeline and
-// returns itself.
-func (pl Pipeline) Drop(n int) Pipeline {
-	for i := 0; i < n; i++ {
-		<-pl
-	}
-	return pl
+// Append emits `vs` after all the elements in Pipeline.
+func (pl Pipeline) Append(vs ...interface{}) Pipeline {
+	return Concat(pl, ForEach(vs...))
 }
 
-// DropAll drops all values in Pipeline.
-func (pl Pipeline) DropAll() {
-	for _ = range pl {
-	}
+// Prepend emits `vs` before all the elements in Pipeline.
+func (pl Pipeline) Prepend(vs ...interface{}) Pipeline {
+	return Concat(ForEach(vs...), pl)
 }
 
-// Map passes each element in Pipeline into MapFunc.
-func (pl Pipeline) Map(f interface{}) Pipeline {
-	var mf MapFunc
-	switch ft := f.(type) {
-	case func(interface{}) interface{}:
-		mf = MapFunc(ft)
-	case MapFunc:
-		mf = ft
-	case Func:
-		mf = ft.ToMapFunc()
-	default:
-		mf = NewFunc(f).ToMapFunc()
-	}
+// PadTo passes Pipeline through and, if it has fewer than `n` elements,
+// emits `fill` until there are `n` of them.
+func (pl Pipeline) PadTo(n int, fill interface{}) Pipeline {
 	return New(func(out chan<- interface{}) {
-		for i := range pl {
-			out <- mf.Map(i)
+		i := 0
+		for v := range pl {
+			out <- v
+			i++
+		}
+		for ; i < n; i++ {
+			out <- fill
 		}
 	})
 }
 
-// Filter drops all the invalid elements in Pipeline.
-func (pl Pipeline) Filter(f interface{}) Pipeline {
-	var ff FilterFunc
-	switch ft := f.(type) {
-	case func(interface{}) bool:
-		ff = FilterFunc(ft)
-	case FilterFunc:
-		ff = ft
-	case Func:
-		ff = ft.ToFilterFunc()
-	default:
-		ff = NewFunc(f).ToFilterFunc()
-	}
+// Truncate passes at most the first `n` elements in Pipeline through
+// and abandons the rest, the dual of PadTo.
+func (pl Pipeline) Truncate(n int) Pipeline {
 	return New(func(out chan<- interface{}) {
-		for i := range pl {
-			if ff.Filter(i) {
-				out <- i
+		if n <= 0 {
+			return
+		}
+		i := 0
+		for v := range pl {
+			out <- v
+			if i++; i == n {
+				return
 			}
 		}
 	})
 }
 
-// Reduce reduces all elements in Pipeline to a final result.
-func (pl Pipeline) Reduce(f, init interface{}) interface{} {
-	var rf ReduceFunc
-	switch ft := f.(type) {
-	case func(interface{}, interface{}) interface{}:
-		rf = ReduceFunc(rf)
-	case ReduceFunc:
-		rf = ft
-	case Func:
-		rf = ft.ToReduceFunc()
-	default:
-		rf = NewFunc(f).ToReduceFunc()
-	}
-	result := init
-	for i := range pl {
-		result = rf.Reduce(i, result)
-	}
-	return result
-}
-
 // Maybe type
 type Maybe struct {
 	v interface{}
@@ -234,17 +3172,7 @@ func (m *Maybe) Map(f interface{}) *Maybe {
 	if m == Nothing {
 		return Nothing
 	}
-	var mf MapFunc
-	switch ft := f.(type) {
-	case func(interface{}) interface{}:
-		mf = MapFunc(ft)
-	case MapFunc:
-		mf = ft
-	case Func:
-		mf = ft.ToMapFunc()
-	default:
-		mf = NewFunc(f).ToMapFunc()
-	}
+	mf := toMapFunc(f)
 	return Just(mf.Map(m.v))
 }
 
@@ -268,20 +3196,91 @@ func (m *Maybe) String() string {
 	return fmt.Sprintf("Just %v", m.v)
 }
 
+// Result holds either a value or an error.
+type Result struct {
+	v   interface{}
+	err error
+}
+
+// Ok creates a Result holding value v.
+func Ok(v interface{}) *Result {
+	return &Result{v: v}
+}
+
+// Err creates a Result holding error err.
+func Err(err error) *Result {
+	return &Result{err: err}
+}
+
+// Unwrap returns the value and the error in Result.
+func (r *Result) Unwrap() (interface{}, error) {
+	return r.v, r.err
+}
+
+// IsOk reports whether Result holds a value rather than an error.
+func (r *Result) IsOk() bool {
+	return r.err == nil
+}
+
+func (r *Result) String() string {
+	if r.err != nil {
+		return fmt.Sprintf("Err %v", r.err)
+	}
+	return fmt.Sprintf("Ok %v", r.v)
+}
+
+// Pair holds two values.
+type Pair struct {
+	first, second interface{}
+}
+
+// NewPair creates a Pair of first and second.
+func NewPair(first, second interface{}) Pair {
+	return Pair{first, second}
+}
+
+// First returns the first value in Pair.
+func (p Pair) First() interface{} {
+	return p.first
+}
+
+// Second returns the second value in Pair.
+func (p Pair) Second() interface{} {
+	return p.second
+}
+
+func (p Pair) String() string {
+	return fmt.Sprintf("(%v, %v)", p.first, p.second)
+}
+
 // Func type
 type Func func(...interface{}) reflect.Value
 
 // NewFunc create a new Func.
 func NewFunc(f interface{}) Func {
 	return func(args ...interface{}) reflect.Value {
-		fv := reflect.ValueOf(f)
-		var vargs []reflect.Value
-		for _, arg := range args {
-			vargs = append(vargs, reflect.ValueOf(arg))
+		return callAll(f, args...)[0]
+	}
+}
+
+// callAll calls f with args through reflection and returns all results.
+// A nil arg is passed as the zero value of its parameter type.
+func callAll(f interface{}, args ...interface{}) []reflect.Value {
+	fv := reflect.ValueOf(f)
+	ft := fv.Type()
+	var vargs []reflect.Value
+	for i, arg := range args {
+		av := reflect.ValueOf(arg)
+		switch {
+		case arg != nil:
+		case ft.IsVariadic() && i >= ft.NumIn()-1:
+			av = reflect.Zero(ft.In(ft.NumIn() - 1).Elem())
+		case i < ft.NumIn():
+			av = reflect.Zero(ft.In(i))
 		}
-		results := fv.Call(vargs)
-		return results[0]
+		vargs = append(vargs, av)
 	}
+	return fv.Call(vargs)
 }
 
 // Call applies args to Func
@@ -331,6 +3330,19 @@ func (f Func) ToMapFunc() MapFunc {
 	}
 }
 
+func toMapFunc(f interface{}) MapFunc {
+	switch ft := f.(type) {
+	case func(interface{}) interface{}:
+		return MapFunc(ft)
+	case MapFunc:
+		return ft
+	case Func:
+		return ft.ToMapFunc()
+	default:
+		return NewFunc(f).ToMapFunc()
+	}
+}
+
 // FilterFunc type
 type FilterFunc func(interface{}) bool
 
@@ -353,6 +3365,19 @@ func (f Func) ToFilterFunc() FilterFunc {
 	}
 }
 
+func toFilterFunc(f interface{}) FilterFunc {
+	switch ft := f.(type) {
+	case func(interface{}) bool:
+		return FilterFunc(ft)
+	case FilterFunc:
+		return ft
+	case Func:
+		return ft.ToFilterFunc()
+	default:
+		return NewFunc(f).ToFilterFunc()
+	}
+}
+
 // ReduceFunc type
 type ReduceFunc func(v1, v2 interface{}) interface{}
 
@@ -368,6 +3393,19 @@ func (f Func) ToReduceFunc() ReduceFunc {
 	}
 }
 
+func toReduceFunc(f interface{}) ReduceFunc {
+	switch ft := f.(type) {
+	case func(interface{}, interface{}) interface{}:
+		return ReduceFunc(ft)
+	case ReduceFunc:
+		return ft
+	case Func:
+		return ft.ToReduceFunc()
+	default:
+		return NewFunc(f).ToReduceFunc()
+	}
+}
+
 // NothingFilter is a FilterFunc to fuck all Nothing value
 var NothingFilter = func(m *Maybe) bool {
 	return m != Nothing
@@ -1,8 +1,26 @@
 package gofp
 
 import (
+	"bufio"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestTake(t *testing.T) {
@@ -37,6 +55,53 @@ func TestFirst(t *testing.T) {
 	}
 }
 
+func TestLast(t *testing.T) {
+	if last := Range(5).Last(); last != 4 {
+		t.Errorf("want %d got %v", 4, last)
+	}
+	if last := ForEach().Last(); last != nil {
+		t.Errorf("want %v got %v", nil, last)
+	}
+
+	if all := Range(5).LastN(2); !compareSlice(all, []interface{}{3, 4}) {
+		t.Errorf("want %v got %v", []interface{}{3, 4}, all)
+	}
+	if all := Range(2).LastN(5); !compareSlice(all, []interface{}{0, 1}) {
+		t.Errorf("want %v got %v", []interface{}{0, 1}, all)
+	}
+}
+
+func TestNth(t *testing.T) {
+	pl := Range(10)
+	if v, ok := pl.Nth(3); !ok || v != 2 {
+		t.Errorf("want %d got %v", 2, v)
+	}
+	if v := pl.First(); v.(int) > 4 {
+		t.Errorf("Nth read past %v", v)
+	}
+	if v, ok := Range(3).Nth(4); ok {
+		t.Errorf("want nothing got %v", v)
+	}
+	if v, ok := Range(3).Nth(0); ok {
+		t.Errorf("want nothing got %v", v)
+	}
+}
+
+func TestTakeLastDropLast(t *testing.T) {
+	if all := Range(5).TakeLast(2); !compareSlice(all, []interface{}{3, 4}) {
+		t.Errorf("want %v got %v", []interface{}{3, 4}, all)
+	}
+	if all := Range(5).DropLast(2).TakeAll(); !compareSlice(all, []interface{}{0, 1, 2}) {
+		t.Errorf("want %v got %v", []interface{}{0, 1, 2}, all)
+	}
+	if all := Range(2).DropLast(3).TakeAll(); len(all) != 0 {
+		t.Errorf("want empty got %v", all)
+	}
+	if all := Range(2).DropLast(0).TakeAll(); !compareSlice(all, []interface{}{0, 1}) {
+		t.Errorf("want %v got %v", []interface{}{0, 1}, all)
+	}
+}
+
 func TestDrop(t *testing.T) {
 	pl := Range(0, 10)
 	pl.Drop(5)
@@ -58,6 +123,303 @@ func compareSlice(s1, s2 []interface{}) bool {
 	return true
 }
 
+func TestCount(t *testing.T) {
+	if n := Range(7).Count(); n != 7 {
+		t.Errorf("want %d got %d", 7, n)
+	}
+	if n := ForEach().Count(); n != 0 {
+		t.Errorf("want %d got %d", 0, n)
+	}
+}
+
+func TestChunks(t *testing.T) {
+	all := Chunks(strings.NewReader("abcdefgh"), 3).Map(func(b []byte) string {
+		return string(b)
+	}).TakeAll()
+	if want := []interface{}{"abc", "def", "gh"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = Chunks(strings.NewReader(""), 3).TakeAll()
+	if len(all) != 0 {
+		t.Errorf("want [] got %v", all)
+	}
+}
+
+func TestRunes(t *testing.T) {
+	all := Runes(strings.NewReader("héllo, 世界")).TakeAll()
+	want := []interface{}{'h', 'é', 'l', 'l', 'o', ',', ' ', '世', '界'}
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestFromString(t *testing.T) {
+	if all := FromStringRunes("ab").TakeAll(); !compareSlice(all, []interface{}{'a', 'b'}) {
+		t.Errorf("want %v got %v", []interface{}{'a', 'b'}, all)
+	}
+	if all := FromStringWords(" a  b\nc ").TakeAll(); !compareSlice(all, []interface{}{"a", "b", "c"}) {
+		t.Errorf("want %v got %v", []interface{}{"a", "b", "c"}, all)
+	}
+	if all := FromStringLines("a b\nc").TakeAll(); !compareSlice(all, []interface{}{"a b", "c"}) {
+		t.Errorf("want %v got %v", []interface{}{"a b", "c"}, all)
+	}
+}
+
+func TestFromRegexp(t *testing.T) {
+	log := "GET /a 200\nPOST /b 500\nGET /c 404 GET /d 200\n"
+	all := FromRegexp(regexp.MustCompile(`/\w+`), strings.NewReader(log)).TakeAll()
+	if want := []interface{}{"/a", "/b", "/c", "/d"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = FromRegexp(regexp.MustCompile(`(\w+) /(\w+) 5\d\d`), strings.NewReader(log)).TakeAll()
+	if want := []interface{}{[]string{"POST /b 500", "POST", "b"}}; !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+// makeTree creates files at the slash-separated paths under a temporary
+// directory and returns the directory.
+func makeTree(t *testing.T, paths ...string) string {
+	root := t.TempDir()
+	for _, p := range paths {
+		p = filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestWalkDir(t *testing.T) {
+	root := makeTree(t, "a.go", "b.txt", "sub/c.go", ".git/d.go")
+	rel := func(p string) string {
+		r, _ := filepath.Rel(root, p)
+		return filepath.ToSlash(r)
+	}
+
+	all := WalkDir(root).Map(rel).TakeAll()
+	want := []interface{}{".", ".git", ".git/d.go", "a.go", "b.txt", "sub", "sub/c.go"}
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = WalkDir(root, WalkExt(".go"), WalkSkipDir(".git")).Map(rel).TakeAll()
+	if want := []interface{}{"a.go", "sub/c.go"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = WalkDir(root, WalkFilesOnly(), WalkDirEntries()).Map(fs.DirEntry.Name).TakeAll()
+	if want := []interface{}{"d.go", "a.go", "b.txt", "c.go"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestFromGlob(t *testing.T) {
+	root := makeTree(t, "a.go", "b.txt", "sub/c.go", "sub/deep/d.go", "sub/deep/e.txt")
+	rel := func(p string) string {
+		r, _ := filepath.Rel(root, p)
+		return filepath.ToSlash(r)
+	}
+
+	all := FromGlob(filepath.Join(root, "*.go")).Map(rel).TakeAll()
+	if want := []interface{}{"a.go"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = FromGlob(filepath.Join(root, "**", "*.go")).Map(rel).TakeAll()
+	if want := []interface{}{"a.go", "sub/c.go", "sub/deep/d.go"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = FromGlob(filepath.Join(root, "sub", "**", "*.txt")).Map(rel).TakeAll()
+	if want := []interface{}{"sub/deep/e.txt"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestFromJSONArray(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	all := FromJSONArray(strings.NewReader(`[{"X": 1, "Y": 2}, {"X": 3}]`), point{}).TakeAll()
+	if want := []interface{}{point{1, 2}, point{3, 0}}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = FromJSONArray(strings.NewReader(`[1, "a"]`), nil).TakeAll()
+	if want := []interface{}{1.0, "a"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	var got error
+	all = FromJSONArray(strings.NewReader(`{"X": 1}`), point{}, RecoverReadError(func(err error) (interface{}, bool) {
+		got = err
+		return nil, false
+	})).TakeAll()
+	if len(all) != 0 || got == nil {
+		t.Errorf("want error got %v, %v", all, got)
+	}
+}
+
+func TestFromJSONLines(t *testing.T) {
+	type event struct {
+		Level string
+	}
+	input := "{\"Level\": \"info\"}\n\nnot json\n{\"Level\": \"warn\"}\n"
+	all := FromJSONLines(strings.NewReader(input), event{}).TakeAll()
+	if len(all) != 3 {
+		t.Fatalf("want %d elements got %v", 3, all)
+	}
+	if all[0] != (event{"info"}) || all[2] != (event{"warn"}) {
+		t.Errorf("want %v and %v got %v", event{"info"}, event{"warn"}, all)
+	}
+	if r, ok := all[1].(*Result); !ok || r.IsOk() {
+		t.Errorf("want Err got %v", all[1])
+	}
+
+	long := "{\"Level\": \"" + strings.Repeat("x", 70<<10) + "\"}\n"
+	all = FromJSONLines(strings.NewReader(long+"{\"Level\": \"warn\"}"), event{}).TakeAll()
+	if len(all) != 2 || all[1] != (event{"warn"}) {
+		t.Fatalf("want an Err and %v got %d elements", event{"warn"}, len(all))
+	}
+	if r, ok := all[0].(*Result); !ok || r.IsOk() || !errors.Is(r.err, bufio.ErrTooLong) {
+		t.Errorf("want Err %v got %v", bufio.ErrTooLong, all[0])
+	}
+
+	all = FromJSONLines(strings.NewReader(long), event{}, MaxLineSize(1<<20)).TakeAll()
+	if len(all) != 1 || all[0] != (event{strings.Repeat("x", 70<<10)}) {
+		t.Errorf("want 1 decoded line got %d elements", len(all))
+	}
+}
+
+func TestFromCSV(t *testing.T) {
+	input := "name;note\n# comment\nann;\"likes; tea\"\nbob;x\n"
+	all := FromCSV(strings.NewReader(input), CSVComma(';'), CSVComment('#'), CSVSkipHeader()).TakeAll()
+	want := []interface{}{[]string{"ann", "likes; tea"}, []string{"bob", "x"}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	if n := FromCSV(strings.NewReader("a,b\nc,d\n")).Count(); n != 2 {
+		t.Errorf("want %d got %d", 2, n)
+	}
+}
+
+// fakeDriver serves every query with the same two rows.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                               { return nil }
+func (fakeStmt) NumInput() int                              { return -1 }
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return nil, errors.New("not supported") }
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error)  { return &fakeRows{}, nil }
+
+type fakeRows struct {
+	n int
+}
+
+func (*fakeRows) Columns() []string { return []string{"id", "name"} }
+func (*fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.n >= 2 {
+		return io.EOF
+	}
+	r.n++
+	dest[0], dest[1] = int64(r.n), fmt.Sprint("user", r.n)
+	return nil
+}
+
+func init() {
+	sql.Register("gofp-fake", fakeDriver{})
+}
+
+func TestFromRows(t *testing.T) {
+	db, err := sql.Open("gofp-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := FromRows(rows).TakeAll()
+	want := []interface{}{[]interface{}{int64(1), "user1"}, []interface{}{int64(2), "user2"}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	rows, err = db.Query("SELECT id, name FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all = FromRowsMap(rows).TakeAll()
+	want = []interface{}{
+		map[string]interface{}{"id": int64(1), "name": "user1"},
+		map[string]interface{}{"id": int64(2), "name": "user2"},
+	}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestFromHTTPStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "chunk %d\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := FromHTTPStream(resp).TakeAll()
+	if want := []interface{}{"chunk 0", "chunk 1", "chunk 2"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestFromSSE(t *testing.T) {
+	stream := ": hello\n\nid: 1\nevent: greet\ndata: hi\ndata: there\nretry: 500\n\nevent: empty\n\ndata:bye\n\nid:\ndata: reset\n\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, stream)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := FromSSE(resp).TakeAll()
+	want := []interface{}{
+		SSEEvent{ID: "1", Event: "greet", Data: "hi\nthere", Retry: 500 * time.Millisecond},
+		SSEEvent{ID: "1", Data: "bye"},
+		SSEEvent{Data: "reset"},
+	}
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
 func TestRange(t *testing.T) {
 	cases := []struct {
 		init   int
@@ -81,6 +443,107 @@ func TestRange(t *testing.T) {
 	}
 }
 
+func TestFromMap(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2}
+	entries := FromMap(m).SortBy(func(e Entry) string { return e.Key.(string) }).TakeAll()
+	want := []interface{}{Entry{Key: "a", Value: 1}, Entry{Key: "b", Value: 2}}
+	if !compareSlice(entries, want) {
+		t.Errorf("want %v got %v", want, entries)
+	}
+
+	keys := FromMap(m, MapKeys()).Sorted(func(a, b string) bool { return a < b })
+	if want := []interface{}{"a", "b"}; !compareSlice(keys, want) {
+		t.Errorf("want %v got %v", want, keys)
+	}
+	if sum := FromMap(m, MapValues()).Sum(); sum != 3 {
+		t.Errorf("want %v got %v", 3, sum)
+	}
+}
+
+func TestFromChannel(t *testing.T) {
+	ch := make(chan string, 2)
+	ch <- "a"
+	ch <- "b"
+	close(ch)
+	all := FromChannel(ch).TakeAll()
+	if want := []interface{}{"a", "b"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	nums := make(chan int)
+	go func() {
+		defer close(nums)
+		nums <- 1
+		nums <- 2
+	}()
+	var recv <-chan int = nums
+	if sum := FromChannel(recv).Sum(); sum != 3 {
+		t.Errorf("want %v got %v", 3, sum)
+	}
+}
+
+func TestIterate(t *testing.T) {
+	all := Iterate(1, func(i int) int { return i * 2 }).Take(5)
+	if want := []interface{}{1, 2, 4, 8, 16}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	fib := Iterate(NewPair(0, 1), func(p Pair) Pair {
+		return NewPair(p.Second(), p.First().(int)+p.Second().(int))
+	}).Map(Pair.First).TakeWhile(func(i int) bool { return i < 20 }).TakeAll()
+	if want := []interface{}{0, 1, 1, 2, 3, 5, 8, 13}; !compareSlice(fib, want) {
+		t.Errorf("want %v got %v", want, fib)
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	all := Zip(Range(3), Repeat("x")).TakeAll()
+	want := []interface{}{NewPair(0, "x"), NewPair(1, "x"), NewPair(2, "x")}
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+	if all := RepeatN(7, 3).TakeAll(); !compareSlice(all, []interface{}{7, 7, 7}) {
+		t.Errorf("want %v got %v", []interface{}{7, 7, 7}, all)
+	}
+}
+
+func TestUnfold(t *testing.T) {
+	digits := Unfold(1234, func(n int) (int, int, bool) {
+		return n % 10, n / 10, n > 0
+	}).TakeAll()
+	if want := []interface{}{4, 3, 2, 1}; !compareSlice(digits, want) {
+		t.Errorf("want %v got %v", want, digits)
+	}
+
+	pop := func(rest []int) (int, []int, bool) {
+		if len(rest) == 0 {
+			return 0, nil, false
+		}
+		return rest[0], rest[1:], true
+	}
+	if all := Unfold([]int{1, 2}, pop).TakeAll(); !compareSlice(all, []interface{}{1, 2}) {
+		t.Errorf("want %v got %v", []interface{}{1, 2}, all)
+	}
+	if all := Unfold(nil, pop).TakeAll(); len(all) != 0 {
+		t.Errorf("want [] got %v", all)
+	}
+}
+
+func TestEvery(t *testing.T) {
+	stop := make(chan struct{})
+	ticks := Every(5*time.Millisecond, stop)
+	var last time.Time
+	for i := 0; i < 3; i++ {
+		tick := (<-ticks).(time.Time)
+		if !tick.After(last) {
+			t.Errorf("want tick after %v got %v", last, tick)
+		}
+		last = tick
+	}
+	close(stop)
+	ticks.DropAll()
+}
+
 func TestFromArray(t *testing.T) {
 	array := []interface{}{1, 2, 3, 4}
 	all := FromArray(array).TakeAll()
@@ -114,6 +577,23 @@ func TestFilter(t *testing.T) {
 	}
 }
 
+func TestReject(t *testing.T) {
+	all := Range(6).Reject(func(i int) bool { return i%2 == 0 }).TakeAll()
+	if want := []interface{}{1, 3, 5}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	var nilPtr *int
+	one := 1
+	two := Just(2)
+	all := ForEach(nil, 0, nilPtr, Nothing, &one, two, "").Compact().TakeAll()
+	if want := []interface{}{0, &one, two, ""}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
 func TestReduce(t *testing.T) {
 	result := ForEach(1, 2, 3, 4, 5).Reduce(func(i, j int) int {
 		return i + j
@@ -121,69 +601,1299 @@ func TestReduce(t *testing.T) {
 	if result.(int) != 15 {
 		t.Error("want %d got %d", 15, result)
 	}
+
+	result = ForEach("a", "b", "c").Reduce(func(v, acc interface{}) interface{} {
+		return acc.(string) + v.(string)
+	}, "")
+	if result != "abc" {
+		t.Errorf("want %v got %v", "abc", result)
+	}
 }
 
-func TestMaybe(t *testing.T) {
-	inc := func(i int) int { return i + 1 }
-	if res := Nothing.Map(inc); res != Nothing {
-		t.Errorf("want Nothing got %s", res)
+func TestFlatten(t *testing.T) {
+	words := Lines(strings.NewReader("a b\nc")).Map(func(s string) Pipeline {
+		return Words(strings.NewReader(s))
+	})
+	all := Concat(ForEach([]int{1, 2}, 3, [1]string{"x"}), words).Flatten().TakeAll()
+	if want := []interface{}{1, 2, 3, "x", "a", "b", "c"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
 	}
+}
 
-	if res := Just(1).Map(inc); res.v != Just(2).v {
-		t.Errorf("want %s got %s", Just(2), res)
+func TestFlattenDepth(t *testing.T) {
+	nested := [][][]int{{{1, 2}, {3}}, {{4}}}
+	all := ForEach(nested).FlattenDepth(2).TakeAll()
+	want := []interface{}{[]int{1, 2}, []int{3}, []int{4}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach(nested).FlattenDepth(3).TakeAll()
+	if want := []interface{}{1, 2, 3, 4}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach(nested).FlattenDepth(0).TakeAll()
+	if len(all) != 1 || !reflect.DeepEqual(all[0], nested) {
+		t.Errorf("want %v got %v", nested, all)
 	}
 }
 
-func TestFunc(t *testing.T) {
-	array := []string{
-		"123",
-		"abc",
-		"1a2",
-		"12b",
+func TestReplayLast(t *testing.T) {
+	live, snapshot := Range(10).ReplayLast(3)
+	if all := snapshot().TakeAll(); len(all) > 3 {
+		t.Errorf("want at most %d got %v", 3, all)
+	}
+	live.DropAll()
+	all := snapshot().TakeAll()
+	if want := []interface{}{7, 8, 9}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	live, snapshot = Range(2).ReplayLast(5)
+	live.DropAll()
+	all = snapshot().TakeAll()
+	if want := []interface{}{0, 1}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
 	}
-	FromArray(array).Filter(NewFunc(strings.Contains).FlipCurry("a").ToFilterFunc().Not()).Map(func(s string) string {
-		if strings.Contains(s, "a") {
-			t.Errorf("no char %s in %s", "a", s)
-		}
-		return s
-	}).DropAll()
 }
 
-func BenchmarkMap(b *testing.B) {
-	pl := ForEach(1, 2, 3, 4)
+func TestSplitEvery(t *testing.T) {
+	chunks := Range(7).SplitEvery(3).TakeAll()
+	want := []interface{}{[]interface{}{0, 1, 2}, []interface{}{3, 4, 5}}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("want %v got %v", want, chunks)
+	}
 
-	b.ResetTimer()
-	b.ReportAllocs()
+	chunks = Range(7).SplitEvery(3, SplitPad(-1)).TakeAll()
+	want = append(want, []interface{}{6, -1, -1})
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("want %v got %v", want, chunks)
+	}
+	padded := Range(7).SplitEvery(3, SplitPad(-1)).JoinEvery().TakeAll()
+	if want := Range(7).TakeAll(); !compareSlice(padded[:7], want) {
+		t.Errorf("want %v got %v", want, padded)
+	}
 
-	for i := 0; i < b.N; i++ {
-		pl.Map(func(i int) int {
-			return i + 1
-		}).Map(func(i int) int {
-			return i * 2
-		}).TakeAll()
+	all := Range(6).SplitEvery(2).JoinEvery().TakeAll()
+	if want := Range(6).TakeAll(); !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
 	}
 }
 
-func BenchmarkFilter(b *testing.B) {
-	pl := ForEach(1, 2, 3, 4, 5)
-	b.ResetTimer()
-	b.ReportAllocs()
+func TestRunLength(t *testing.T) {
+	input := []interface{}{"a", "a", "b", "c", "c", "c", "a"}
+	entries := FromArray(input).RunLengthEncode().TakeAll()
+	want := []interface{}{
+		Entry{Value: "a", Count: 2},
+		Entry{Value: "b", Count: 1},
+		Entry{Value: "c", Count: 3},
+		Entry{Value: "a", Count: 1},
+	}
+	if !compareSlice(entries, want) {
+		t.Errorf("want %v got %v", want, entries)
+	}
 
-	for i := 0; i < b.N; i++ {
-		pl.Filter(func(i int) bool {
-			return i%2 == 0
-		}).TakeAll()
+	all := FromArray(input).RunLengthEncode().RunLengthDecode().TakeAll()
+	if !compareSlice(all, input) {
+		t.Errorf("want %v got %v", input, all)
 	}
 }
 
-func BenchmarkReduce(b *testing.B) {
-	pl := ForEach(1, 2, 3, 4, 5)
-	b.ResetTimer()
-	b.ReportAllocs()
+func TestMustComparable(t *testing.T) {
+	for _, v := range []interface{}{nil, 1, "a", Entry{Value: 1}, NewPair("a", [2]interface{}{1, 2})} {
+		mustComparable(v)
+	}
 
-	for i := 0; i < b.N; i++ {
-		pl.Reduce(func(i, j int) int {
-			return i + j
-		}, 0)
+	for _, v := range []interface{}{[]int{1}, Entry{Value: []int{1}}, NewPair([]int{1}, 1), [2]interface{}{[]int{1}, 1}} {
+		func() {
+			want := fmt.Sprintf("uncomparable type %T", v)
+			defer func() {
+				if got := recover(); got != want {
+					t.Errorf("want %v got %v", want, got)
+				}
+			}()
+			mustComparable(v)
+		}()
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	pl := Range(100).Prefetch(8)
+	if c := cap(pl); c != 8 {
+		t.Errorf("want capacity %d got %d", 8, c)
+	}
+	all := pl.TakeAll()
+	if want := Range(100).TakeAll(); !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestRecoverReadError(t *testing.T) {
+	boom := errors.New("boom")
+	r := io.MultiReader(strings.NewReader("a\nb\n"), iotest.ErrReader(boom))
+	var got error
+	all := Lines(r, RecoverReadError(func(err error) (interface{}, bool) {
+		got = err
+		return "recovered", true
+	})).Map(strings.ToUpper).TakeAll()
+	if want := []interface{}{"A", "B", "RECOVERED"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+	if got != boom {
+		t.Errorf("want %v got %v", boom, got)
+	}
+
+	called := false
+	all = Lines(strings.NewReader("a\nb"), RecoverReadError(func(err error) (interface{}, bool) {
+		called = true
+		return nil, false
+	})).TakeAll()
+	if called || len(all) != 2 {
+		t.Errorf("want %d elements and no call got %v", 2, all)
+	}
+
+	got = nil
+	all = FromCSV(strings.NewReader("a,b\n\"c"), CSVReadOptions(RecoverReadError(func(err error) (interface{}, bool) {
+		got = err
+		return nil, false
+	}))).TakeAll()
+	if len(all) != 1 || got == nil {
+		t.Errorf("want 1 record and an error got %v, %v", all, got)
+	}
+
+	long := strings.Repeat("x", 70<<10)
+	got = nil
+	all = Lines(strings.NewReader(long+"\nnext"), RecoverReadError(func(err error) (interface{}, bool) {
+		got = err
+		return nil, false
+	})).TakeAll()
+	if len(all) != 0 || got != bufio.ErrTooLong {
+		t.Errorf("want %v got %d lines, %v", bufio.ErrTooLong, len(all), got)
+	}
+	all = Lines(strings.NewReader(long+"\nnext"), MaxLineSize(1<<20)).TakeAll()
+	if len(all) != 2 || all[0] != long || all[1] != "next" {
+		t.Errorf("want the long line and %v got %d lines", "next", len(all))
+	}
+}
+
+func TestMapReduce(t *testing.T) {
+	counts := Words(strings.NewReader("a b a c b a")).MapReduce(
+		func(string) int { return 1 },
+		func(s string) string { return s },
+		func(v, acc int) int { return v + acc },
+		0,
+	)
+	want := map[interface{}]interface{}{"a": 3, "b": 2, "c": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("want %v got %v", want, counts)
+	}
+}
+
+func TestDistinctWithCount(t *testing.T) {
+	// The panic happens in the producer goroutine, so it's checked from
+	// a child process running only this branch.
+	if os.Getenv("GOFP_TEST_CRASH") == "1" {
+		ForEach(Entry{Value: []int{1}}).DistinctWithCount().DropAll()
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDistinctWithCount$")
+	cmd.Env = append(os.Environ(), "GOFP_TEST_CRASH=1")
+	out, err := cmd.CombinedOutput()
+	if want := "panic: uncomparable type gofp.Entry"; err == nil || !strings.Contains(string(out), want) {
+		t.Errorf("want %v got %v: %s", want, err, out)
+	}
+
+	entries := ForEach("b", "a", "b", "c", "b", "a").DistinctWithCount().TakeAll()
+	want := []interface{}{
+		Entry{Value: "b", Count: 3},
+		Entry{Value: "a", Count: 2},
+		Entry{Value: "c", Count: 1},
+	}
+	if !compareSlice(entries, want) {
+		t.Errorf("want %v got %v", want, entries)
+	}
+}
+
+func TestUnwrapAll(t *testing.T) {
+	values, err := ForEach(Ok(1), Ok(2)).UnwrapAll()
+	if err != nil || !compareSlice(values, []interface{}{1, 2}) {
+		t.Errorf("want %v got %v, %v", []interface{}{1, 2}, values, err)
+	}
+
+	boom := errors.New("boom")
+	values, err = ForEach(Ok(1), Err(boom), Ok(3)).UnwrapAll()
+	if err != boom {
+		t.Errorf("want %v got %v", boom, err)
+	}
+	if !compareSlice(values, []interface{}{1}) {
+		t.Errorf("want %v got %v", []interface{}{1}, values)
+	}
+}
+
+func TestSkipUntilSignal(t *testing.T) {
+	src := make(chan interface{})
+	signal := make(chan struct{})
+	go func() {
+		defer close(src)
+		src <- 1
+		src <- 2
+		time.Sleep(10 * time.Millisecond)
+		close(signal)
+		src <- 3
+		src <- 4
+	}()
+	all := Pipeline(src).SkipUntilSignal(signal).TakeAll()
+	if want := []interface{}{3, 4}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	if all := Range(3).SkipUntilSignal(nil).TakeAll(); len(all) != 0 {
+		t.Errorf("want empty got %v", all)
+	}
+}
+
+func TestWeightedSampleReservoir(t *testing.T) {
+	weight := func(i int) float64 { return float64(i + 1) }
+	sample := Range(100).WeightedSampleReservoir(5, weight, rand.New(rand.NewSource(42)))
+	if want := []interface{}{91, 33, 81, 97, 35}; !compareSlice(sample, want) {
+		t.Errorf("want %v got %v", want, sample)
+	}
+
+	all := Range(3).WeightedSampleReservoir(5, weight, rand.New(rand.NewSource(42)))
+	if len(all) != 3 {
+		t.Errorf("want %d elements got %v", 3, all)
+	}
+}
+
+func TestFirstWithin(t *testing.T) {
+	if m, err := Range(1, 3).FirstWithin(time.Second); err != nil || m.v != 1 {
+		t.Errorf("want %v got %v, %v", Just(1), m, err)
+	}
+	if m, err := ForEach().FirstWithin(time.Second); err != nil || m != Nothing {
+		t.Errorf("want %v got %v, %v", Nothing, m, err)
+	}
+
+	slow := New(func(out chan<- interface{}) {
+		time.Sleep(50 * time.Millisecond)
+		out <- 1
+	})
+	if _, err := slow.FirstWithin(5 * time.Millisecond); err != ErrTimeout {
+		t.Errorf("want %v got %v", ErrTimeout, err)
+	}
+}
+
+func TestMergeMap(t *testing.T) {
+	counts := countInts(Range(1, 5).MergeMap(2, func(i int) Pipeline {
+		return Range(i)
+	}))
+	for i := 0; i < 4; i++ {
+		if want := 4 - i; counts[i] != want {
+			t.Errorf("want %d copies of %d got %d", want, i, counts[i])
+		}
+	}
+}
+
+func countInts(pl Pipeline) map[int]int {
+	counts := make(map[int]int)
+	for v := range pl {
+		counts[v.(int)]++
+	}
+	return counts
+}
+
+func TestConcatMap(t *testing.T) {
+	all := Range(1, 4).ConcatMap(func(i int) Pipeline {
+		return Range(i*10, i*10+i)
+	}).TakeAll()
+	want := []interface{}{10, 20, 21, 30, 31, 32}
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestSwitchMap(t *testing.T) {
+	all := Range(1, 4).SwitchMap(func(i int) Pipeline {
+		return New(func(out chan<- interface{}) {
+			if i < 3 {
+				time.Sleep(50 * time.Millisecond)
+			}
+			out <- i * 10
+			out <- i*10 + 1
+		})
+	}).TakeAll()
+	if want := []interface{}{30, 31}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestCombineLatest(t *testing.T) {
+	a, b := make(chan interface{}), make(chan interface{})
+	go func() {
+		a <- 1
+		b <- "x"
+		a <- 2
+		b <- "y"
+		close(a)
+		b <- "z"
+		close(b)
+	}()
+	all := CombineLatest(a, b).TakeAll()
+	want := []interface{}{
+		[2]interface{}{1, "x"},
+		[2]interface{}{2, "x"},
+		[2]interface{}{2, "y"},
+		[2]interface{}{2, "z"},
+	}
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestWithLatestFrom(t *testing.T) {
+	events, config := make(chan interface{}), make(chan interface{})
+	go func() {
+		events <- 0
+		config <- "a"
+		events <- 1
+		events <- 2
+		config <- "b"
+		close(config)
+		events <- 3
+		close(events)
+	}()
+	all := Pipeline(events).WithLatestFrom(config, func(i int, s string) string {
+		return fmt.Sprint(s, i)
+	}).TakeAll()
+	if want := []interface{}{"a1", "a2", "b3"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestBufferUntil(t *testing.T) {
+	src, trigger := make(chan interface{}), make(chan struct{})
+	go func() {
+		src <- 1
+		src <- 2
+		trigger <- struct{}{}
+		trigger <- struct{}{}
+		src <- 3
+		trigger <- struct{}{}
+		src <- 4
+		close(src)
+	}()
+	all := Pipeline(src).BufferUntil(trigger).TakeAll()
+	want := []interface{}{[]interface{}{1, 2}, []interface{}{3}, []interface{}{4}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestSegment(t *testing.T) {
+	log := "noise\n# one\na\nb\n# two\n# three\nc"
+	all := Lines(strings.NewReader(log)).Segment(func(s string) bool {
+		return strings.HasPrefix(s, "#")
+	}).TakeAll()
+	want := []interface{}{
+		[]interface{}{"noise"},
+		[]interface{}{"# one", "a", "b"},
+		[]interface{}{"# two"},
+		[]interface{}{"# three", "c"},
+	}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	avg := func(group []interface{}) float64 {
+		sum := 0
+		for _, v := range group {
+			sum += v.(int)
+		}
+		return float64(sum) / float64(len(group))
+	}
+	all := Range(10).Downsample(4, avg).TakeAll()
+	if want := []interface{}{1.5, 5.5, 8.5}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestGroupByOrdered(t *testing.T) {
+	entries := ForEach("banana", "apple", "cherry", "avocado", "blueberry").GroupByOrdered(func(s string) byte {
+		return s[0]
+	})
+	want := []Entry{
+		{Key: byte('b'), Value: []interface{}{"banana", "blueberry"}},
+		{Key: byte('a'), Value: []interface{}{"apple", "avocado"}},
+		{Key: byte('c'), Value: []interface{}{"cherry"}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("want %v got %v", want, entries)
+	}
+}
+
+func TestExpandTree(t *testing.T) {
+	tree := map[int][]int{1: {2, 3}, 2: {4, 5}, 3: {6}}
+	children := func(i int) []int { return tree[i] }
+
+	all := ForEach(1).ExpandTree(children).TakeAll()
+	if want := []interface{}{1, 2, 3, 4, 5, 6}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+	all = ForEach(1).ExpandTreeDFS(children).TakeAll()
+	if want := []interface{}{1, 2, 4, 5, 3, 6}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+	all = ForEach(1).ExpandTree(children, ExpandMaxDepth(1)).TakeAll()
+	if want := []interface{}{1, 2, 3}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	cycle := func(i int) []int { return []int{(i + 1) % 3} }
+	all = ForEach(0).ExpandTree(cycle, ExpandUnique()).TakeAll()
+	if want := []interface{}{0, 1, 2}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestObserveLatency(t *testing.T) {
+	steady := New(func(out chan<- interface{}) {
+		for i := 0; i < 20; i++ {
+			time.Sleep(2 * time.Millisecond)
+			out <- i
+		}
+	})
+	pl, percentiles := steady.ObserveLatency()
+	pl.DropAll()
+	p50, p95, p99 := percentiles()
+	if p50 < time.Millisecond || p50 > 100*time.Millisecond {
+		t.Errorf("want p50 around %v got %v", 2*time.Millisecond, p50)
+	}
+	if p50 > p95 || p95 > p99 {
+		t.Errorf("want ordered percentiles got %v %v %v", p50, p95, p99)
+	}
+}
+
+func TestFilterAccum(t *testing.T) {
+	all := ForEach(3, 1, 4, 1, 5, 9, 2, 6).FilterAccum(func(max, i int) (int, bool) {
+		if i > max {
+			return i, true
+		}
+		return max, false
+	}, -1).TakeAll()
+	if want := []interface{}{3, 4, 5, 9}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach(3, 1, 4, 1, 5).FilterAccum(func(max *int, i int) (*int, bool) {
+		if max != nil && i <= *max {
+			return max, false
+		}
+		return &i, true
+	}, nil).TakeAll()
+	if want := []interface{}{3, 4, 5}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestMapFlat(t *testing.T) {
+	all := Range(1, 5).MapFlat(func(i int) interface{} {
+		switch {
+		case i%2 == 0:
+			return []int{i, i}
+		case i == 3:
+			return Range(3)
+		default:
+			return i
+		}
+	}).TakeAll()
+	if want := []interface{}{1, 2, 2, 0, 1, 2, 4, 4}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestFlatMap(t *testing.T) {
+	all := ForEach("a b", "c").FlatMap(strings.Fields).TakeAll()
+	if want := []interface{}{"a", "b", "c"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = Range(1, 4).FlatMap(func(i int) Pipeline {
+		return Range(i)
+	}).TakeAll()
+	if want := []interface{}{0, 0, 1, 0, 1, 2}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestZip(t *testing.T) {
+	all := Zip(Range(3), ForEach("a", "b")).TakeAll()
+	if want := []interface{}{NewPair(0, "a"), NewPair(1, "b")}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	p := Range(5).Zip(Range(5, 10)).Drop(2).First().(Pair)
+	if p.First() != 2 || p.Second() != 7 {
+		t.Errorf("want %v got %v", NewPair(2, 7), p)
+	}
+}
+
+func TestZipWith(t *testing.T) {
+	all := ZipWith(ForEach("a", "b", "c"), Range(1, 4), strings.Repeat).TakeAll()
+	want := []interface{}{"a", "bb", "ccc"}
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	all := Concat(Range(2), ForEach(), Range(2, 4)).TakeAll()
+	if want := []interface{}{0, 1, 2, 3}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	counts := countInts(Merge(Range(3), Range(2), ForEach()))
+	if want := map[int]int{0: 2, 1: 2, 2: 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("want %v got %v", want, counts)
+	}
+}
+
+func TestTakeWhile(t *testing.T) {
+	all := ForEach(1, 2, 3, 10, 4).TakeWhile(func(i int) bool {
+		return i < 5
+	}).TakeAll()
+	if want := []interface{}{1, 2, 3}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestDropWhile(t *testing.T) {
+	all := Lines(strings.NewReader("# a\n# b\nc\n# d")).DropWhile(func(s string) bool {
+		return strings.HasPrefix(s, "#")
+	}).TakeAll()
+	if want := []interface{}{"c", "# d"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	all := ForEach(1, 2, 1, 3, 2, 1).Distinct().TakeAll()
+	if want := []interface{}{1, 2, 3}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach([]int{1}, []int{2}, []int{1}).Distinct().TakeAll()
+	if want := []interface{}{[]int{1}, []int{2}}; !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach(NewPair([]int{1}, 1), NewPair(1, 1), NewPair([]int{1}, 1)).Distinct().TakeAll()
+	if want := []interface{}{NewPair([]int{1}, 1), NewPair(1, 1)}; !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestSorted(t *testing.T) {
+	all := ForEach(3, 1, 2).Sorted(func(a, b int) bool { return a < b })
+	if want := []interface{}{1, 2, 3}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach("bb", "a", "cc", "d").Sorted(func(a, b string) bool {
+		return len(a) < len(b)
+	})
+	if want := []interface{}{"a", "d", "bb", "cc"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	all := ForEach("ccc", "a", "bb", "d").SortBy(func(s string) int {
+		return len(s)
+	}).TakeAll()
+	if want := []interface{}{"a", "d", "bb", "ccc"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach(2.5, 1.5, -1.0).SortBy(func(f float64) float64 { return f }).TakeAll()
+	if want := []interface{}{-1.0, 1.5, 2.5}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach("b", 2.5, "a", 1).SortBy(func(v interface{}) interface{} { return v }).TakeAll()
+	if want := []interface{}{1, 2.5, "a", "b"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	all := Lines(strings.NewReader("a\nb\nc")).Reverse().TakeAll()
+	if want := []interface{}{"c", "b", "a"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	parity := func(i int) bool { return i%2 == 0 }
+	groups := Range(5).GroupBy(parity)
+	want := map[interface{}][]interface{}{true: {0, 2, 4}, false: {1, 3}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("want %v got %v", want, groups)
+	}
+
+	typed, ok := Range(5).GroupByTyped(parity).(map[bool][]interface{})
+	if !ok {
+		t.Fatalf("want %T got %T", map[bool][]interface{}{}, typed)
+	}
+	if !reflect.DeepEqual(typed[true], want[true]) || !reflect.DeepEqual(typed[false], want[false]) {
+		t.Errorf("want %v got %v", want, typed)
+	}
+
+	boom := errors.New("boom")
+	errs := Range(5).GroupByTyped(func(i int) error {
+		if i%2 == 0 {
+			return nil
+		}
+		return boom
+	})
+	wantErrs := map[error][]interface{}{nil: {0, 2, 4}, boom: {1, 3}}
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Errorf("want %v got %v", wantErrs, errs)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	even, odd := Range(100).Partition(func(i int) bool { return i%2 == 0 })
+	odds := odd.TakeAll()
+	evens := even.TakeAll()
+	if len(evens) != 50 || len(odds) != 50 {
+		t.Fatalf("want %d and %d got %d and %d", 50, 50, len(evens), len(odds))
+	}
+	for i := range evens {
+		if evens[i] != 2*i || odds[i] != 2*i+1 {
+			t.Errorf("want %d and %d got %v and %v", 2*i, 2*i+1, evens[i], odds[i])
+		}
+	}
+}
+
+func TestChunk(t *testing.T) {
+	all := Range(5).Chunk(2).TakeAll()
+	want := []interface{}{[]interface{}{0, 1}, []interface{}{2, 3}, []interface{}{4}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	cases := []struct {
+		size, step int
+		result     []interface{}
+	}{
+		{3, 1, []interface{}{[]interface{}{0, 1, 2}, []interface{}{1, 2, 3}, []interface{}{2, 3, 4}}},
+		{2, 2, []interface{}{[]interface{}{0, 1}, []interface{}{2, 3}}},
+		{1, 3, []interface{}{[]interface{}{0}, []interface{}{3}}},
+	}
+
+	for _, c := range cases {
+		if all := Range(5).Window(c.size, c.step).TakeAll(); !reflect.DeepEqual(all, c.result) {
+			t.Errorf("want %v got %v", c.result, all)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	all := ForEach(1, 2, 3, 4).Scan(func(i, j int) int {
+		return i + j
+	}, 0).TakeAll()
+	if want := []interface{}{1, 3, 6, 10}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestTap(t *testing.T) {
+	var seen []string
+	all := ForEach("a", "b").Tap(func(s string) {
+		seen = append(seen, s)
+	}).TakeAll()
+	if want := []interface{}{"a", "b"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+	if len(seen) != 2 || seen[0] != "a" || seen[1] != "b" {
+		t.Errorf("want %v got %v", all, seen)
+	}
+}
+
+func TestSumMinMax(t *testing.T) {
+	if sum := ForEach(1, int64(2), 3.5).Sum(); sum != 6.5 {
+		t.Errorf("want %v got %v", 6.5, sum)
+	}
+	if min := ForEach(3, -2, 5).Min(); min != -2 {
+		t.Errorf("want %v got %v", -2, min)
+	}
+	if max := ForEach(1.5, 2.5, -3.0).Max(); max != 2.5 {
+		t.Errorf("want %v got %v", 2.5, max)
+	}
+	if min := ForEach().Min(); min != nil {
+		t.Errorf("want %v got %v", nil, min)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := ForEach(2, 4, 4, 4, 5, 5, 7, 9).Stats()
+	want := Stats{Count: 8, Sum: 40, Mean: 5, Min: 2, Max: 9, Variance: 4, StdDev: 2}
+	if s != want {
+		t.Errorf("want %+v got %+v", want, s)
+	}
+	if s := ForEach().Stats(); s != (Stats{}) {
+		t.Errorf("want %+v got %+v", Stats{}, s)
+	}
+}
+
+func TestAnyAllNone(t *testing.T) {
+	even := func(i int) bool { return i%2 == 0 }
+	if !ForEach(1, 3, 4).Any(even) || ForEach(1, 3).Any(even) {
+		t.Errorf("Any got wrong answer")
+	}
+	if !ForEach(2, 4).All(even) || ForEach(2, 3).All(even) || !ForEach().All(even) {
+		t.Errorf("All got wrong answer")
+	}
+	if !ForEach(1, 3).None(even) || ForEach(1, 2).None(even) {
+		t.Errorf("None got wrong answer")
+	}
+
+	pl := Range(1, 100)
+	if !pl.Any(even) {
+		t.Errorf("want %v got %v", true, false)
+	}
+	if next := pl.First(); next.(int) > 4 {
+		t.Errorf("Any read past %v", next)
+	}
+}
+
+func TestContains(t *testing.T) {
+	if !Range(10).Contains(3) || Range(10).Contains(10) {
+		t.Errorf("Contains got wrong answer for ints")
+	}
+	if !ForEach([]int{1}, []int{2}).Contains([]int{2}) || ForEach([]int{1}).Contains([]int{3}) {
+		t.Errorf("Contains got wrong answer for slices")
+	}
+	v := [2]interface{}{[]int{1}, 1}
+	if !ForEach(1, v).Contains([2]interface{}{[]int{1}, 1}) || ForEach(v).Contains([2]interface{}{[]int{2}, 1}) {
+		t.Errorf("Contains got wrong answer for arrays holding slices")
+	}
+}
+
+func TestFind(t *testing.T) {
+	v, ok := ForEach("a", "bb", "cc").Find(func(s string) bool { return len(s) == 2 })
+	if !ok || v != "bb" {
+		t.Errorf("want %v got %v", "bb", v)
+	}
+	if v, ok := Range(5).Find(func(i int) bool { return i > 5 }); ok {
+		t.Errorf("want nothing got %v", v)
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	all := ForEach("a", "b").Enumerate().TakeAll()
+	if want := []interface{}{Indexed{0, "a"}, Indexed{1, "b"}}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestTee(t *testing.T) {
+	pls := Range(100).Tee(3)
+	if len(pls) != 3 {
+		t.Fatalf("want %d pipelines got %d", 3, len(pls))
+	}
+	if n := pls[2].Count(); n != 100 {
+		t.Errorf("want %d got %d", 100, n)
+	}
+	if sum := pls[0].Sum(); sum != 4950 {
+		t.Errorf("want %v got %v", 4950, sum)
+	}
+	if max := pls[1].Max(); max != 99 {
+		t.Errorf("want %v got %v", 99, max)
+	}
+}
+
+func TestBuffered(t *testing.T) {
+	pl := Range(10).Buffered(4)
+	if c := cap(pl); c != 4 {
+		t.Errorf("want capacity %d got %d", 4, c)
+	}
+	if all := pl.TakeAll(); !compareSlice(all, Range(10).TakeAll()) {
+		t.Errorf("want %v got %v", Range(10).TakeAll(), all)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	start := time.Now()
+	all := Range(6).Throttle(2, 50*time.Millisecond).TakeAll()
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("want at least %v got %v", 90*time.Millisecond, elapsed)
+	}
+	if want := Range(6).TakeAll(); !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	bursts := New(func(out chan<- interface{}) {
+		out <- 1
+		out <- 2
+		out <- 3
+		time.Sleep(100 * time.Millisecond)
+		out <- 4
+		out <- 5
+	})
+	all := bursts.Debounce(20 * time.Millisecond).TakeAll()
+	if want := []interface{}{3, 5}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestSample(t *testing.T) {
+	all := Range(1, 11).Sample(3).TakeAll()
+	if want := []interface{}{3, 6, 9}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	if n := Range(100).SampleP(0).Count(); n != 0 {
+		t.Errorf("want %d got %d", 0, n)
+	}
+	if n := Range(100).SampleP(1).Count(); n != 100 {
+		t.Errorf("want %d got %d", 100, n)
+	}
+	if n := Range(10000).SampleP(0.5).Count(); n < 4000 || n > 6000 {
+		t.Errorf("want about %d got %d", 5000, n)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	all := Range(3).Cycle(2).TakeAll()
+	if want := []interface{}{0, 1, 2, 0, 1, 2}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach("a", "b").Cycle(0).Take(5)
+	if want := []interface{}{"a", "b", "a", "b", "a"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestIntersperse(t *testing.T) {
+	all := Range(3).Intersperse(",").TakeAll()
+	if want := []interface{}{0, ",", 1, ",", 2}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+	if all := ForEach().Intersperse(",").TakeAll(); len(all) != 0 {
+		t.Errorf("want empty got %v", all)
+	}
+}
+
+func TestJoinStrings(t *testing.T) {
+	if s := ForEach("a", 1, 2.5, Just(3)).JoinStrings(", "); s != "a, 1, 2.5, Just 3" {
+		t.Errorf("want %q got %q", "a, 1, 2.5, Just 3", s)
+	}
+	if s := ForEach().JoinStrings(","); s != "" {
+		t.Errorf("want %q got %q", "", s)
+	}
+}
+
+func TestPairwise(t *testing.T) {
+	deltas := ForEach(1, 4, 9, 16).Pairwise().Map(func(p Pair) int {
+		return p.Second().(int) - p.First().(int)
+	}).TakeAll()
+	if want := []interface{}{3, 5, 7}; !compareSlice(deltas, want) {
+		t.Errorf("want %v got %v", want, deltas)
+	}
+	if all := ForEach(1).Pairwise().TakeAll(); len(all) != 0 {
+		t.Errorf("want empty got %v", all)
+	}
+}
+
+func TestCross(t *testing.T) {
+	all := Cross(Range(2), ForEach("x", "y")).TakeAll()
+	want := []interface{}{NewPair(0, "x"), NewPair(0, "y"), NewPair(1, "x"), NewPair(1, "y")}
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	a := func() Pipeline { return ForEach(1, 2, 2, 3, 4) }
+	b := func() Pipeline { return ForEach(3, 4, 4, 5) }
+
+	if all := Union(a(), b()).TakeAll(); !compareSlice(all, []interface{}{1, 2, 3, 4, 5}) {
+		t.Errorf("want %v got %v", []interface{}{1, 2, 3, 4, 5}, all)
+	}
+	if all := a().Intersect(b()).TakeAll(); !compareSlice(all, []interface{}{3, 4}) {
+		t.Errorf("want %v got %v", []interface{}{3, 4}, all)
+	}
+	if all := Difference(a(), b()).TakeAll(); !compareSlice(all, []interface{}{1, 2}) {
+		t.Errorf("want %v got %v", []interface{}{1, 2}, all)
+	}
+
+	p, q := NewPair([]int{1}, 1), NewPair([]int{2}, 2)
+	all := Union(ForEach(p, q), ForEach(NewPair([]int{1}, 1))).TakeAll()
+	if want := []interface{}{p, q}; !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+	all = Intersect(ForEach(p, q), ForEach(NewPair([]int{2}, 2))).TakeAll()
+	if want := []interface{}{q}; !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestDedupConsecutive(t *testing.T) {
+	all := ForEach(1, 1, 2, 2, 2, 1, 3, 3).DedupConsecutive().TakeAll()
+	if want := []interface{}{1, 2, 1, 3}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach([]int{1}, []int{1}, []int{2}).DedupConsecutive().TakeAll()
+	if want := []interface{}{[]int{1}, []int{2}}; !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach(NewPair([]int{1}, 1), NewPair([]int{1}, 1), NewPair(1, 1)).DedupConsecutive().TakeAll()
+	if want := []interface{}{NewPair([]int{1}, 1), NewPair(1, 1)}; !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestMapIndexed(t *testing.T) {
+	all := ForEach("a", "b").MapIndexed(func(i int, s string) string {
+		return fmt.Sprintf("%d:%s", i+1, s)
+	}).TakeAll()
+	if want := []interface{}{"1:a", "2:b"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestFilterIndexed(t *testing.T) {
+	all := ForEach("header", "a", "b", "c").FilterIndexed(func(i int, s string) bool {
+		return i > 0 && i%2 == 1
+	}).TakeAll()
+	if want := []interface{}{"a", "c"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	shuffled := Range(20).Shuffle(rand.NewSource(1)).TakeAll()
+	again := Range(20).Shuffle(rand.NewSource(1)).TakeAll()
+	if !compareSlice(shuffled, again) {
+		t.Errorf("want %v got %v", shuffled, again)
+	}
+	if compareSlice(shuffled, Range(20).TakeAll()) {
+		t.Errorf("want shuffled got %v", shuffled)
+	}
+	sorted := FromArray(shuffled).Sorted(func(a, b int) bool { return a < b })
+	if !compareSlice(sorted, Range(20).TakeAll()) {
+		t.Errorf("want permutation of %v got %v", Range(20).TakeAll(), shuffled)
+	}
+}
+
+func TestMinByMaxBy(t *testing.T) {
+	type player struct {
+		name  string
+		score int
+	}
+	players := []player{{"a", 3}, {"b", 7}, {"c", 1}, {"d", 7}}
+	score := func(p player) int { return p.score }
+	if min := FromArray(players).MinBy(score); min != players[2] {
+		t.Errorf("want %v got %v", players[2], min)
+	}
+	if max := FromArray(players).MaxBy(score); max != players[1] {
+		t.Errorf("want %v got %v", players[1], max)
+	}
+	if max := ForEach().MaxBy(score); max != nil {
+		t.Errorf("want %v got %v", nil, max)
+	}
+}
+
+func TestFoldRight(t *testing.T) {
+	result := ForEach("a", "b", "c").FoldRight(func(s, acc string) string {
+		return "(" + s + " " + acc + ")"
+	}, "nil")
+	if want := "(a (b (c nil)))"; result != want {
+		t.Errorf("want %v got %v", want, result)
+	}
+}
+
+func TestUnzip(t *testing.T) {
+	nums, letters := Unzip(Zip(Range(3), ForEach("a", "b", "c")).Map(func(p Pair) Pair {
+		return NewPair(p.First().(int)*10, p.Second())
+	}))
+	if all := letters.TakeAll(); !compareSlice(all, []interface{}{"a", "b", "c"}) {
+		t.Errorf("want %v got %v", []interface{}{"a", "b", "c"}, all)
+	}
+	if all := nums.TakeAll(); !compareSlice(all, []interface{}{0, 10, 20}) {
+		t.Errorf("want %v got %v", []interface{}{0, 10, 20}, all)
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	all := Interleave(Range(3), ForEach("a"), ForEach(), ForEach("x", "y")).TakeAll()
+	if want := []interface{}{0, "a", "x", 1, "y", 2}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestGroupAdjacent(t *testing.T) {
+	all := ForEach("apple", "avocado", "banana", "apricot").GroupAdjacent(func(s string) byte {
+		return s[0]
+	}).TakeAll()
+	want := []interface{}{
+		[]interface{}{"apple", "avocado"},
+		[]interface{}{"banana"},
+		[]interface{}{"apricot"},
+	}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestSplitWhen(t *testing.T) {
+	text := "a\nb\n\n\nc\n"
+	empty := func(s string) bool { return s == "" }
+	all := Lines(strings.NewReader(text)).SplitWhen(empty).TakeAll()
+	want := []interface{}{[]interface{}{"a", "b"}, []interface{}{"c"}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = ForEach(1, 0, 2, 3, 0).SplitWhen(func(i int) bool { return i == 0 }, SplitKeepDelimiter()).TakeAll()
+	want = []interface{}{[]interface{}{1, 0}, []interface{}{2, 3, 0}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestCountBy(t *testing.T) {
+	counts := Words(strings.NewReader("the cat and the hat")).CountBy(nil)
+	want := map[interface{}]int{"the": 2, "cat": 1, "and": 1, "hat": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("want %v got %v", want, counts)
+	}
+
+	counts = Range(10).CountBy(func(i int) bool { return i < 3 })
+	if want := map[interface{}]int{true: 3, false: 7}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("want %v got %v", want, counts)
+	}
+}
+
+func TestTopN(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	top := ForEach(5, 1, 9, 3, 7, 9, 2).TopN(3, less)
+	if want := []interface{}{9, 9, 7}; !compareSlice(top, want) {
+		t.Errorf("want %v got %v", want, top)
+	}
+	if top := ForEach(2, 1).TopN(5, less); !compareSlice(top, []interface{}{2, 1}) {
+		t.Errorf("want %v got %v", []interface{}{2, 1}, top)
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	ps := Range(1, 101).Shuffle(rand.NewSource(1)).Percentiles(0, 50, 90, 100)
+	want := map[float64]float64{0: 1, 50: 50.5, 90: 90.1, 100: 100}
+	for p, w := range want {
+		if math.Abs(ps[p]-w) > 1e-9 {
+			t.Errorf("p%v: want %v got %v", p, w, ps[p])
+		}
+	}
+	if ps := ForEach().Percentiles(50); len(ps) != 0 {
+		t.Errorf("want empty got %v", ps)
+	}
+
+	for _, pl := range []Pipeline{ForEach(), ForEach(1, 2)} {
+		func() {
+			want := "need percentile in [0, 100], got 200"
+			defer func() {
+				if got := recover(); got != want {
+					t.Errorf("want %v got %v", want, got)
+				}
+			}()
+			pl.Percentiles(200)
+		}()
+	}
+}
+
+func TestAppendPrepend(t *testing.T) {
+	all := Range(1, 3).Append("footer").Prepend("header", 0).TakeAll()
+	if want := []interface{}{"header", 0, 1, 2, "footer"}; !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestPadToTruncate(t *testing.T) {
+	if all := Range(2).PadTo(4, -1).TakeAll(); !compareSlice(all, []interface{}{0, 1, -1, -1}) {
+		t.Errorf("want %v got %v", []interface{}{0, 1, -1, -1}, all)
+	}
+	if all := Range(3).PadTo(2, -1).TakeAll(); !compareSlice(all, []interface{}{0, 1, 2}) {
+		t.Errorf("want %v got %v", []interface{}{0, 1, 2}, all)
+	}
+	if all := Range(5).Truncate(2).TakeAll(); !compareSlice(all, []interface{}{0, 1}) {
+		t.Errorf("want %v got %v", []interface{}{0, 1}, all)
+	}
+	if all := Range(5).Truncate(0).TakeAll(); len(all) != 0 {
+		t.Errorf("want empty got %v", all)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	type user struct {
+		id   int
+		name string
+	}
+	type order struct {
+		user int
+		item string
+	}
+	users := func() Pipeline { return ForEach(user{1, "ann"}, user{2, "bob"}) }
+	orders := func() Pipeline { return ForEach(order{1, "tea"}, order{3, "pen"}, order{1, "cup"}) }
+	userID := func(u user) int { return u.id }
+	orderUser := func(o order) int { return o.user }
+
+	all := Join(users(), orders(), userID, orderUser).TakeAll()
+	want := []interface{}{
+		NewPair(user{1, "ann"}, order{1, "tea"}),
+		NewPair(user{1, "ann"}, order{1, "cup"}),
+	}
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = Join(users(), orders(), userID, orderUser, JoinLeft()).TakeAll()
+	want = append(want, NewPair(user{2, "bob"}, nil))
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+
+	all = Join(users(), orders(), userID, orderUser, JoinOuter()).TakeAll()
+	want = append(want, NewPair(nil, order{3, "pen"}))
+	if !compareSlice(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestChunkByKey(t *testing.T) {
+	lines := "a 1\na 2\nb 3\nc 4\nc 5"
+	all := Lines(strings.NewReader(lines)).ChunkByKey(func(s string) string {
+		return strings.Fields(s)[0]
+	}).TakeAll()
+	want := []interface{}{
+		Entry{Key: "a", Value: []interface{}{"a 1", "a 2"}},
+		Entry{Key: "b", Value: []interface{}{"b 3"}},
+		Entry{Key: "c", Value: []interface{}{"c 4", "c 5"}},
+	}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want %v got %v", want, all)
+	}
+}
+
+func TestMaybe(t *testing.T) {
+	inc := func(i int) int { return i + 1 }
+	if res := Nothing.Map(inc); res != Nothing {
+		t.Errorf("want Nothing got %s", res)
+	}
+
+	if res := Just(1).Map(inc); res.v != Just(2).v {
+		t.Errorf("want %s got %s", Just(2), res)
+	}
+}
+
+func TestFunc(t *testing.T) {
+	array := []string{
+		"123",
+		"abc",
+		"1a2",
+		"12b",
+	}
+	FromArray(array).Filter(NewFunc(strings.Contains).FlipCurry("a").ToFilterFunc().Not()).Map(func(s string) string {
+		if strings.Contains(s, "a") {
+			t.Errorf("no char %s in %s", "a", s)
+		}
+		return s
+	}).DropAll()
+}
+
+func BenchmarkMap(b *testing.B) {
+	pl := ForEach(1, 2, 3, 4)
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		pl.Map(func(i int) int {
+			return i + 1
+		}).Map(func(i int) int {
+			return i * 2
+		}).TakeAll()
+	}
+}
+
+func BenchmarkFilter(b *testing.B) {
+	pl := ForEach(1, 2, 3, 4, 5)
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		pl.Filter(func(i int) bool {
+			return i%2 == 0
+		}).TakeAll()
+	}
+}
+
+func BenchmarkReduce(b *testing.B) {
+	pl := ForEach(1, 2, 3, 4, 5)
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		pl.Reduce(func(i, j int) int {
+			return i + j
+		}, 0)
+	}
+}
+
+func burstyPipeline() Pipeline {
+	return New(func(out chan<- interface{}) {
+		for i := 0; i < 256; i++ {
+			if i%64 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			out <- i
+		}
+	})
+}
+
+func slowConsume(pl Pipeline) {
+	i := 0
+	for range pl {
+		i++
+		if i%64 == 32 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func BenchmarkBursty(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		slowConsume(burstyPipeline())
+	}
+}
+
+func BenchmarkBurstyPrefetch(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		slowConsume(burstyPipeline().Prefetch(64))
 	}
 }